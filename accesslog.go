@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+)
+
+// responseRecorder remembers the status and body size written through it
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (s *responseRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *responseRecorder) Write(b []byte) (int, error) {
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (s *responseRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// logRequests logs every request as key=value pairs with its status,
+// response size and duration. Requests are logged when accessLog is set and
+// otherwise only at debug level; the client address and scheme are taken
+// from a trusted proxy's headers with trustProxy.
+func logRequests(next http.Handler, accessLog, trustProxy bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !accessLog && !debugEnabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		log.Printf("access method=%s path=%q status=%d bytes=%d duration=%s client=%s scheme=%s",
+			r.Method, r.URL.Path, recorder.status, recorder.bytes, time.Since(start).Round(time.Microsecond),
+			clientAddr(r, trustProxy), requestScheme(r, trustProxy))
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// probePaths stay open when authentication is enabled so kubelet probes
+// don't need credentials. They expose nothing about the tenant's data.
+var probePaths = map[string]bool{"/healthz": true, "/readyz": true}
+
+// authConfig describes how requests to the exporter are authenticated. At
+// most one of a bearer token or a basic auth user is configured.
+type authConfig struct {
+	token    string
+	user     string
+	password string
+	// trustProxy takes the client address logged for rejected requests
+	// from X-Forwarded-For
+	trustProxy bool
+}
+
+func (a authConfig) enabled() bool {
+	return a.token != "" || a.user != ""
+}
+
+// scheme names the configured authentication for /config
+func (a authConfig) scheme() string {
+	switch {
+	case a.token != "":
+		return "bearer"
+	case a.user != "":
+		return "basic"
+	}
+	return "none"
+}
+
+func (a authConfig) validate() error {
+	if a.token != "" && (a.user != "" || a.password != "") {
+		return fmt.Errorf("VANTAGE_AUTH_TOKEN and VANTAGE_AUTH_USER/VANTAGE_AUTH_PASS are mutually exclusive")
+	}
+	if (a.user == "") != (a.password == "") {
+		return fmt.Errorf("VANTAGE_AUTH_USER and VANTAGE_AUTH_PASS must be set together")
+	}
+	return nil
+}
+
+// requireAuth wraps next so every request other than the probes must carry
+// the configured credentials, answering 401 otherwise
+func (a authConfig) requireAuth(next http.Handler) http.Handler {
+	if !a.enabled() {
+		return next
+	}
+
+	scheme := "Bearer"
+	if a.user != "" {
+		scheme = `Basic realm="vantage-exporter"`
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probePaths[r.URL.Path] || a.authorized(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		log.Printf("Rejected unauthenticated request for %s from %s", r.URL.Path, clientAddr(r, a.trustProxy))
+		w.Header().Set("WWW-Authenticate", scheme)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
+
+func (a authConfig) authorized(r *http.Request) bool {
+	if a.token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return ok && secretEqual(token, a.token)
+	}
+	user, password, ok := r.BasicAuth()
+	// Compare both so a wrong user takes as long as a wrong password
+	userOK := secretEqual(user, a.user)
+	passwordOK := secretEqual(password, a.password)
+	return ok && userOK && passwordOK
+}
+
+// secretEqual compares in constant time. Hashing first keeps the comparison
+// from leaking the secret's length.
+func secretEqual(given, want string) bool {
+	g := sha256.Sum256([]byte(given))
+	w := sha256.Sum256([]byte(want))
+	return subtle.ConstantTimeCompare(g[:], w[:]) == 1
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// TestClientSecretNeverLogged makes the token endpoint echo the request
+// back in its error and checks that neither the log nor the endpoints'
+// errors contain the secret
+func TestClientSecretNeverLogged(t *testing.T) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(io.Discard)
+	debugEnabled = true
+	defer func() { debugEnabled = false }()
+
+	c := newTestCollector(t, fakeAPI{
+		"token": func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(w, `{"error":"invalid_client","request":"`+string(body)+`"}`)
+		},
+	})
+	secret := "s3cr3t-value"
+
+	testutil.CollectAndCount(c)
+	if _, err := c.getToken(context.Background()); err == nil {
+		t.Fatal("getToken succeeded against a failing token endpoint")
+	} else if msg := err.Error(); strings.Contains(msg, secret) {
+		t.Errorf("token error leaks the client secret: %s", msg)
+	}
+
+	for _, form := range []string{secret, url.QueryEscape(secret)} {
+		if strings.Contains(logs.String(), form) {
+			t.Errorf("log output contains the client secret %q:\n%s", form, logs.String())
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// errCircuitOpen is returned without a network call while an endpoint's
+// circuit is open
+var errCircuitOpen = errors.New("circuit open after consecutive Vantage API failures")
+
+// circuitBreaker stops calling an endpoint that keeps failing so scrapes
+// fail fast instead of each waiting out its timeouts. After threshold
+// consecutive failures the endpoint's circuit opens for cooldown; then a
+// single probe request is let through, closing the circuit on success and
+// reopening it on failure.
+type circuitBreaker struct {
+	threshold int // 0 disables the breaker
+	cooldown  time.Duration
+	onChange  func(endpoint string, open bool)
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	failures int
+	openedAt time.Time // zero while closed
+	probing  bool
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration, onChange func(endpoint string, open bool)) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		onChange:  onChange,
+		circuits:  make(map[string]*circuit),
+	}
+}
+
+// allow reports whether a request to endpoint may be sent. Every allowed
+// request must be followed by a call to record.
+func (b *circuitBreaker) allow(endpoint string) error {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(endpoint)
+	if c.openedAt.IsZero() {
+		return nil
+	}
+	if wait := b.cooldown - time.Since(c.openedAt); wait > 0 || c.probing {
+		return fmt.Errorf("%s: %w, retrying in %s", endpoint, errCircuitOpen, max(wait, 0).Round(time.Second))
+	}
+	c.probing = true
+	return nil
+}
+
+// record updates endpoint's circuit with the outcome of an allowed request.
+// Only outages count: errors and 5xx responses fail, other responses
+// succeed, and requests abandoned by the caller or throttled by the
+// exporter itself count as neither.
+func (b *circuitBreaker) record(endpoint string, resp *http.Response, err error) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(endpoint)
+	wasProbing := c.probing
+	c.probing = false
+	switch {
+	case errors.Is(err, context.Canceled) || errors.Is(err, errThrottled):
+		return
+	case err != nil || resp.StatusCode >= http.StatusInternalServerError:
+		c.failures++
+		if wasProbing || (c.openedAt.IsZero() && c.failures >= b.threshold) {
+			if c.openedAt.IsZero() {
+				b.onChange(endpoint, true)
+			}
+			c.openedAt = time.Now()
+		}
+	default:
+		c.failures = 0
+		if !c.openedAt.IsZero() {
+			c.openedAt = time.Time{}
+			b.onChange(endpoint, false)
+		}
+	}
+}
+
+// circuit returns endpoint's circuit. The caller must hold mu.
+func (b *circuitBreaker) circuit(endpoint string) *circuit {
+	c, ok := b.circuits[endpoint]
+	if !ok {
+		c = &circuit{}
+		b.circuits[endpoint] = c
+	}
+	return c
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+const (
+	// defaultRuleErrorTransactions is how many of the most recent completed
+	// transactions /business-rules-errors inspects when no limit is given
+	defaultRuleErrorTransactions = 50
+	// defaultRuleErrorTop is how many messages the top list holds by default
+	defaultRuleErrorTop = 10
+)
+
+// RuleErrorCount is how often one business rule error message occurred
+type RuleErrorCount struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Count   int    `json:"count"`
+}
+
+// BusinessRulesErrorsResponse summarizes business rule errors across the
+// inspected transactions
+type BusinessRulesErrorsResponse struct {
+	TransactionsInspected int                         `json:"transactions_inspected"`
+	TransactionsFailed    int                         `json:"transactions_failed"`
+	ByType                map[string][]RuleErrorCount `json:"by_type"`
+	Top                   []RuleErrorCount            `json:"top"`
+}
+
+// handleBusinessRulesErrors fetches the detail of recent completed
+// transactions and reports their business rule error messages grouped by
+// type, most frequent first, plus the overall top messages. skills narrows
+// the transactions, limit bounds how many are inspected and top how many
+// messages are listed.
+func (c *vantageCollector) handleBusinessRulesErrors(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := c.skillFilter
+	if param := query.Get("skills"); param != "" {
+		skillIDs, err := parseSkillIDs(param)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter = filter.narrow(skillIDs)
+	}
+
+	limit, ok := positiveParam(w, query.Get("limit"), "limit", defaultRuleErrorTransactions)
+	if !ok {
+		return
+	}
+	top, ok := positiveParam(w, query.Get("top"), "top", defaultRuleErrorTop)
+	if !ok {
+		return
+	}
+
+	completed, err := c.getCompletedTransactions(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get completed transactions: %v", err), http.StatusBadGateway)
+		return
+	}
+	completed = filter.transactions(completed)
+	if len(completed) > limit {
+		completed = completed[:limit]
+	}
+
+	counts := make(map[[2]string]int)
+	response := BusinessRulesErrorsResponse{ByType: make(map[string][]RuleErrorCount)}
+	for _, tx := range completed {
+		detail, err := c.getTransactionDetail(r.Context(), tx.ID)
+		if err != nil {
+			log.Printf("Error getting detail for transaction %s: %v", tx.ID, err)
+			response.TransactionsFailed++
+			continue
+		}
+		response.TransactionsInspected++
+		for _, doc := range detail.Documents {
+			for _, ruleErr := range doc.BusinessRulesErrors {
+				counts[[2]string{ruleErr.Type, ruleErr.Message}]++
+			}
+		}
+	}
+
+	all := make([]RuleErrorCount, 0, len(counts))
+	for key, count := range counts {
+		all = append(all, RuleErrorCount{Type: key[0], Message: key[1], Count: count})
+	}
+	sortRuleErrors(all)
+
+	for _, entry := range all {
+		response.ByType[entry.Type] = append(response.ByType[entry.Type], entry)
+	}
+	response.Top = all[:min(top, len(all))]
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// sortRuleErrors orders counts most frequent first, then by type and message
+// so the output is stable
+func sortRuleErrors(counts []RuleErrorCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		if counts[i].Type != counts[j].Type {
+			return counts[i].Type < counts[j].Type
+		}
+		return counts[i].Message < counts[j].Message
+	})
+}
+
+// positiveParam parses an optional positive integer query parameter, writing
+// a 400 and returning false when it is malformed
+func positiveParam(w http.ResponseWriter, value, name string, defaultValue int) (int, bool) {
+	if value == "" {
+		return defaultValue, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		http.Error(w, fmt.Sprintf("%s must be a positive integer", name), http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
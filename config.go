@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileKeys maps YAML config file keys to the environment variable each
+// one stands in for. Environment variables take precedence over the file.
+var configFileKeys = map[string]string{
+	"tenant":                  "VANTAGE_TENANT",
+	"base_url":                "VANTAGE_BASE_URL",
+	"client_id":               "VANTAGE_CLIENT_ID",
+	"client_secret":           "VANTAGE_CLIENT_SECRET",
+	"oauth_scope":             "VANTAGE_OAUTH_SCOPE",
+	"token_path":              "VANTAGE_TOKEN_PATH",
+	"auth_mode":               "VANTAGE_AUTH_MODE",
+	"api_key":                 "VANTAGE_API_KEY",
+	"api_key_header":          "VANTAGE_API_KEY_HEADER",
+	"port":                    "VANTAGE_METRICS_PORT",
+	"tenants_file":            "VANTAGE_TENANTS_FILE",
+	"metrics_path":            "VANTAGE_METRICS_PATH",
+	"skills_path":             "VANTAGE_SKILLS_PATH",
+	"details_path":            "VANTAGE_DETAILS_PATH",
+	"auth_token":              "VANTAGE_AUTH_TOKEN",
+	"auth_user":               "VANTAGE_AUTH_USER",
+	"auth_pass":               "VANTAGE_AUTH_PASS",
+	"trust_proxy":             "VANTAGE_TRUST_PROXY",
+	"access_log":              "VANTAGE_ACCESS_LOG",
+	"cors_origins":            "VANTAGE_CORS_ORIGINS",
+	"pushgateway_url":         "VANTAGE_PUSHGATEWAY_URL",
+	"pushgateway_job":         "VANTAGE_PUSHGATEWAY_JOB",
+	"pushgateway_interval":    "VANTAGE_PUSHGATEWAY_INTERVAL",
+	"pushgateway_grouping":    "VANTAGE_PUSHGATEWAY_GROUPING",
+	"max_pages":               "VANTAGE_MAX_PAGES",
+	"page_limit":              "VANTAGE_PAGE_LIMIT",
+	"lookback":                "VANTAGE_LOOKBACK",
+	"completed_cursor":        "VANTAGE_COMPLETED_CURSOR",
+	"max_detail_skills":       "VANTAGE_MAX_DETAIL_SKILLS",
+	"details_cache_ttl":       "VANTAGE_DETAILS_CACHE_TTL",
+	"skills_cache_ttl":        "VANTAGE_SKILLS_CACHE_TTL",
+	"enable_detail_metrics":   "VANTAGE_ENABLE_DETAIL_METRICS",
+	"detail_concurrency":      "VANTAGE_DETAIL_CONCURRENCY",
+	"skill_concurrency":       "VANTAGE_SKILL_CONCURRENCY",
+	"detail_max":              "VANTAGE_DETAIL_MAX",
+	"disable_per_transaction": "VANTAGE_DISABLE_PER_TRANSACTION",
+	"per_completed":           "VANTAGE_PER_COMPLETED",
+	"native_histogram_factor": "VANTAGE_NATIVE_HISTOGRAM_FACTOR",
+	"disabled_metrics":        "VANTAGE_DISABLED_METRICS",
+	"duration_buckets":        "VANTAGE_DURATION_BUCKETS",
+	"debug":                   "VANTAGE_DEBUG",
+	"validate":                "VANTAGE_VALIDATE",
+	"ready_staleness":         "VANTAGE_READY_STALENESS",
+	"shutdown_timeout":        "VANTAGE_SHUTDOWN_TIMEOUT",
+	"scrape_timeout":          "VANTAGE_SCRAPE_TIMEOUT",
+	"collect_interval":        "VANTAGE_COLLECT_INTERVAL",
+	"http_timeout":            "VANTAGE_HTTP_TIMEOUT",
+	"detail_timeout":          "VANTAGE_DETAIL_TIMEOUT",
+	"proxy_url":               "VANTAGE_PROXY_URL",
+	"user_agent":              "VANTAGE_USER_AGENT",
+	"correlation_header":      "VANTAGE_CORRELATION_HEADER",
+	"ca_cert":                 "VANTAGE_CA_CERT",
+	"tls_insecure":            "VANTAGE_TLS_INSECURE",
+	"api_concurrency":         "VANTAGE_API_CONCURRENCY",
+	"api_rate":                "VANTAGE_API_RATE",
+	"api_burst":               "VANTAGE_API_BURST",
+	"circuit_failures":        "VANTAGE_CIRCUIT_FAILURES",
+	"circuit_cooldown":        "VANTAGE_CIRCUIT_COOLDOWN",
+	"api_queue_timeout":       "VANTAGE_API_QUEUE_TIMEOUT",
+	"max_retry_after":         "VANTAGE_MAX_RETRY_AFTER",
+	"max_response_bytes":      "VANTAGE_MAX_RESPONSE_BYTES",
+	"status_mapping":          "VANTAGE_STATUS_MAPPING",
+	"counted_statuses":        "VANTAGE_COUNTED_STATUSES",
+	"failure_reasons":         "VANTAGE_FAILURE_REASONS",
+	"skill_name_label":        "VANTAGE_SKILL_NAME_LABEL",
+	"skill_allowlist":         "VANTAGE_SKILL_ALLOWLIST",
+	"skill_denylist":          "VANTAGE_SKILL_DENYLIST",
+	"skill_types":             "VANTAGE_SKILL_TYPES",
+	"seen_cache_size":         "VANTAGE_SEEN_CACHE_SIZE",
+	"transaction_cache_size":  "VANTAGE_TRANSACTION_CACHE_SIZE",
+	"transaction_cache_ttl":   "VANTAGE_TRANSACTION_CACHE_TTL",
+	"detail_cache_size":       "VANTAGE_DETAIL_CACHE_SIZE",
+	"detail_cache_ttl":        "VANTAGE_DETAIL_CACHE_TTL",
+}
+
+// fileConfig holds settings loaded from the config file, keyed by the
+// environment variable name they correspond to
+var fileConfig = map[string]string{}
+
+// fileTenants holds the tenants listed under the config file's tenants key
+var fileTenants []tenantConfig
+
+// loadConfigFile reads a YAML config file into fileConfig and fileTenants.
+// Unknown keys are rejected so typos don't silently fall back to defaults.
+func loadConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var raw map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	var tenants []tenantConfig
+	for key, node := range raw {
+		if key == "tenants" {
+			if err := decodeStrict(&node, &tenants); err != nil {
+				return fmt.Errorf("%s: invalid tenants: %w", path, err)
+			}
+			if err := normalizeTenants(tenants); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			continue
+		}
+
+		envKey, ok := configFileKeys[key]
+		if !ok {
+			return fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+		value, err := scalarValue(&node)
+		if err != nil {
+			return fmt.Errorf("%s: setting %q: %w", path, key, err)
+		}
+		values[envKey] = value
+	}
+
+	fileConfig = values
+	fileTenants = tenants
+	return nil
+}
+
+// decodeStrict decodes a node, rejecting fields the target does not declare
+func decodeStrict(node *yaml.Node, out interface{}) error {
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	return dec.Decode(out)
+}
+
+// scalarValue renders a YAML scalar, or a sequence of scalars such as
+// histogram buckets, in the string form the matching env var expects
+func scalarValue(node *yaml.Node) (string, error) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Value, nil
+	case yaml.SequenceNode:
+		parts := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return "", fmt.Errorf("list items must be plain values")
+			}
+			parts = append(parts, item.Value)
+		}
+		return strings.Join(parts, ","), nil
+	default:
+		return "", fmt.Errorf("expected a value or list of values")
+	}
+}
+
+// settingSource names where the value of a setting comes from
+func settingSource(envKey string) string {
+	if os.Getenv(envKey) != "" {
+		return "environment"
+	}
+	if fileConfig[envKey] != "" {
+		return "config file"
+	}
+	return "default"
+}
+
+// logConfigSources reports at debug level where each setting came from
+func logConfigSources() {
+	keys := make([]string, 0, len(configFileKeys))
+	for key := range configFileKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		envKey := configFileKeys[key]
+		debugf("Setting %s (%s) from %s", key, envKey, settingSource(envKey))
+	}
+	if len(fileTenants) > 0 {
+		debugf("Tenants (%d) from config file", len(fileTenants))
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// redacted stands in for a secret in /config
+const redacted = "****"
+
+// secretSettings are shown by /config only as redacted
+var secretSettings = map[string]bool{
+	"VANTAGE_CLIENT_SECRET": true,
+	"VANTAGE_API_KEY":       true,
+	"VANTAGE_AUTH_TOKEN":    true,
+	"VANTAGE_AUTH_PASS":     true,
+}
+
+// ConfigSetting is one setting as it was given, and where it came from
+type ConfigSetting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value,omitempty"`
+	Source string `json:"source"`
+}
+
+// ServerConfig is the resolved configuration of the exporter's own server
+type ServerConfig struct {
+	Port                string `json:"port"`
+	MetricsPath         string `json:"metrics_path"`
+	SkillsPath          string `json:"skills_path"`
+	DetailsPath         string `json:"details_path"`
+	Authentication      string `json:"authentication"`
+	TrustProxy          bool   `json:"trust_proxy"`
+	AccessLog           bool   `json:"access_log"`
+	PushgatewayURL      string `json:"pushgateway_url,omitempty"`
+	PushgatewayJob      string `json:"pushgateway_job,omitempty"`
+	PushgatewayInterval string `json:"pushgateway_interval,omitempty"`
+}
+
+// TenantSettings is the resolved configuration of one tenant's collector
+type TenantSettings struct {
+	Name            string `json:"name"`
+	BaseURL         string `json:"base_url"`
+	AuthMode        string `json:"auth_mode"`
+	ClientID        string `json:"client_id,omitempty"`
+	ClientSecret    string `json:"client_secret,omitempty"`
+	APIKey          string `json:"api_key,omitempty"`
+	ProxyURL        string `json:"proxy_url,omitempty"`
+	ScrapeTimeout   string `json:"scrape_timeout"`
+	HTTPTimeout     string `json:"http_timeout"`
+	DetailTimeout   string `json:"detail_timeout"`
+	CollectInterval string `json:"collect_interval"`
+	Lookback        string `json:"lookback"`
+	SkillsCacheTTL  string `json:"skills_cache_ttl"`
+	DetailsCacheTTL string `json:"details_cache_ttl"`
+	MaxPages        int    `json:"max_pages"`
+	PageLimit       int    `json:"page_limit"`
+	PerTransaction  bool   `json:"per_transaction"`
+	DetailMetrics   bool   `json:"detail_metrics"`
+	CompletedCursor bool   `json:"completed_cursor"`
+}
+
+// ConfigResponse is the body of /config
+type ConfigResponse struct {
+	Version  string           `json:"version"`
+	Server   ServerConfig     `json:"server"`
+	Tenants  []TenantSettings `json:"tenants"`
+	Settings []ConfigSetting  `json:"settings"`
+}
+
+// configHandler serves the effective configuration with every secret
+// redacted: the resolved server and tenant settings, and each setting as
+// given along with whether it came from the environment, the config file or
+// the default.
+func configHandler(opts options, collectors []*vantageCollector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := ConfigResponse{
+			Version: version,
+			Server: ServerConfig{
+				Port:           opts.port,
+				MetricsPath:    opts.metricsPath,
+				SkillsPath:     opts.skillsPath,
+				DetailsPath:    opts.detailsPath,
+				Authentication: opts.auth.scheme(),
+				TrustProxy:     opts.trustProxy,
+				AccessLog:      opts.accessLog,
+			},
+			Tenants:  []TenantSettings{},
+			Settings: []ConfigSetting{},
+		}
+		if opts.push.url != "" {
+			response.Server.PushgatewayURL = redactURL(opts.push.url)
+			response.Server.PushgatewayJob = opts.push.job
+			response.Server.PushgatewayInterval = opts.push.interval.String()
+		}
+
+		for _, c := range collectors {
+			response.Tenants = append(response.Tenants, c.settings())
+		}
+
+		for _, envKey := range configFileKeys {
+			value := lookupSetting(envKey)
+			switch {
+			case value == "":
+			case secretSettings[envKey]:
+				value = redacted
+			default:
+				value = redactURL(value)
+			}
+			response.Settings = append(response.Settings, ConfigSetting{Name: envKey, Value: value, Source: settingSource(envKey)})
+		}
+		sort.Slice(response.Settings, func(i, j int) bool {
+			return response.Settings[i].Name < response.Settings[j].Name
+		})
+
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+func (c *vantageCollector) settings() TenantSettings {
+	s := TenantSettings{
+		Name:            c.tenant,
+		BaseURL:         c.baseURL,
+		AuthMode:        c.authMode,
+		ProxyURL:        redactURL(c.proxyURL),
+		ScrapeTimeout:   c.scrapeTimeout.String(),
+		HTTPTimeout:     c.httpTimeout.String(),
+		DetailTimeout:   c.detailTimeout.String(),
+		CollectInterval: c.collectInterval.String(),
+		Lookback:        c.lookback.String(),
+		SkillsCacheTTL:  c.skillsCacheTTL.String(),
+		DetailsCacheTTL: c.detailsCacheTTL.String(),
+		MaxPages:        c.maxPages,
+		PageLimit:       c.pageLimit,
+		PerTransaction:  c.perTransaction,
+		DetailMetrics:   c.enableDetailMetrics,
+		CompletedCursor: c.completedCursor,
+	}
+	if c.authMode == authModeOAuth2 {
+		s.ClientID = c.clientID
+		s.ClientSecret = redactedIfSet(c.clientSecret)
+	} else {
+		s.APIKey = redactedIfSet(c.apiKey)
+	}
+	return s
+}
+
+func redactedIfSet(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// redactURL hides the password of a URL with credentials, such as a proxy or
+// Pushgateway URL, and returns any other value unchanged
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	return u.Redacted()
+}
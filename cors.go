@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// corsConfig adds CORS headers to the JSON endpoints so browser-based
+// dashboards can call them directly. /metrics is left alone.
+type corsConfig struct {
+	origins  map[string]bool // "*" allows any origin
+	paths    map[string]bool
+	prefixes []string
+}
+
+// newCORSConfig parses a comma-separated origin list. jsonPaths are matched
+// exactly, or as a prefix when they end in a slash.
+func newCORSConfig(origins string, jsonPaths ...string) corsConfig {
+	c := corsConfig{origins: toSet(splitList(origins)), paths: make(map[string]bool)}
+	for _, path := range jsonPaths {
+		if strings.HasSuffix(path, "/") {
+			c.prefixes = append(c.prefixes, path)
+			continue
+		}
+		c.paths[path] = true
+	}
+	return c
+}
+
+func (c corsConfig) enabled() bool {
+	return len(c.origins) > 0
+}
+
+func (c corsConfig) jsonPath(path string) bool {
+	if c.paths[path] {
+		return true
+	}
+	for _, prefix := range c.prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowCORS wraps next, which must be the whole handler chain including
+// authentication: browsers send preflight requests without credentials, so
+// they are answered here before reaching it.
+func (c corsConfig) allowCORS(next http.Handler) http.Handler {
+	if !c.enabled() {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" || !c.jsonPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		switch {
+		case c.origins["*"]:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+		case c.origins[origin]:
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// reasonOther is the reason of failures whose error text matches no pattern
+const reasonOther = "other"
+
+// failureReasonPattern maps error text containing substring to reason
+type failureReasonPattern struct {
+	substring string
+	reason    string
+}
+
+// defaultFailureReasons are checked in order against the lower-cased error
+// text of failed transactions; the first match wins
+var defaultFailureReasons = []failureReasonPattern{
+	{"timeout", "timeout"},
+	{"timed out", "timeout"},
+	{"ocr", "ocr"},
+	{"recogni", "ocr"},
+	{"unsupported", "unsupported_file"},
+	{"corrupt", "invalid_file"},
+	{"password", "invalid_file"},
+	{"invalid file", "invalid_file"},
+	{"too large", "file_too_large"},
+	{"size limit", "file_too_large"},
+	{"classif", "classification"},
+	{"business rule", "business_rules"},
+	{"export", "export"},
+	{"connect", "connection"},
+	{"unauthorized", "permission"},
+	{"permission", "permission"},
+	{"access denied", "permission"},
+	{"quota", "quota"},
+	{"limit exceeded", "quota"},
+	{"cancel", "canceled"},
+	{"internal", "internal"},
+}
+
+// failureClassifier maps the free-form error text of failed transactions to
+// a bounded set of reason codes, keeping the reason label's cardinality low
+type failureClassifier []failureReasonPattern
+
+// newFailureClassifier returns the default patterns preceded by overrides, a
+// comma-separated list of substring=reason pairs, so overrides win
+func newFailureClassifier(overrides string) failureClassifier {
+	var f failureClassifier
+	for _, pair := range strings.Split(overrides, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		substring, reason, ok := strings.Cut(pair, "=")
+		substring = normalizeStatus(substring)
+		reason = strings.TrimSpace(reason)
+		if !ok || substring == "" || reason == "" {
+			log.Printf("Ignoring invalid failure reason mapping %q (want substring=reason)", pair)
+			continue
+		}
+		f = append(f, failureReasonPattern{substring, reason})
+	}
+	return append(f, defaultFailureReasons...)
+}
+
+// classify returns the reason of the first pattern found in errorText,
+// "other" when none matches and "unknown" when the text is empty
+func (f failureClassifier) classify(errorText string) string {
+	text := normalizeStatus(errorText)
+	if text == "" {
+		return statusUnknown
+	}
+	for _, pattern := range f {
+		if strings.Contains(text, pattern.substring) {
+			return pattern.reason
+		}
+	}
+	return reasonOther
+}
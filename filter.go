@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// skillFilter decides which skills have series emitted on /metrics
+type skillFilter struct {
+	allow map[string]bool // nil allows every skill
+	deny  map[string]bool
+}
+
+func newSkillFilter(allow, deny []string) skillFilter {
+	f := skillFilter{deny: toSet(deny)}
+	if len(allow) > 0 {
+		f.allow = toSet(allow)
+	}
+	return f
+}
+
+func (f skillFilter) allows(skillID string) bool {
+	if f.deny[skillID] {
+		return false
+	}
+	return f.allow == nil || f.allow[skillID]
+}
+
+// narrow returns a filter that additionally requires skills to be in ids
+func (f skillFilter) narrow(ids []string) skillFilter {
+	// A non-nil but empty allow set matches nothing, which is the right
+	// answer when none of the requested skills are permitted
+	allow := make(map[string]bool)
+	for _, id := range ids {
+		if f.allows(id) {
+			allow[id] = true
+		}
+	}
+	return skillFilter{allow: allow, deny: f.deny}
+}
+
+func (f skillFilter) skills(skills []Skill) []Skill {
+	var kept []Skill
+	for _, skill := range skills {
+		if f.allows(skill.ID) {
+			kept = append(kept, skill)
+		}
+	}
+	return kept
+}
+
+func (f skillFilter) transactions(transactions []Transaction) []Transaction {
+	var kept []Transaction
+	for _, tx := range transactions {
+		if f.allows(tx.SkillID) {
+			kept = append(kept, tx)
+		}
+	}
+	return kept
+}
+
+func newSkillTypes(types []string) map[string]bool {
+	if len(types) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(types))
+	for _, t := range types {
+		set[strings.ToLower(t)] = true
+	}
+	return set
+}
+
+// filterSkillTypes drops the skills whose type VANTAGE_SKILL_TYPES doesn't
+// list, returning the kept skills and the IDs of the dropped ones
+func (c *vantageCollector) filterSkillTypes(skills []Skill) ([]Skill, map[string]bool) {
+	if c.skillTypes == nil {
+		return skills, nil
+	}
+	kept := []Skill{}
+	excluded := make(map[string]bool)
+	for _, skill := range skills {
+		if c.skillTypes[strings.ToLower(skill.Type)] {
+			kept = append(kept, skill)
+		} else {
+			excluded[skill.ID] = true
+		}
+	}
+	return kept, excluded
+}
+
+// typeExcludedSkills returns the IDs of the skills whose transactions are
+// skipped for their type. Without the skills list there is nothing to decide
+// by, so every transaction is kept.
+func (c *vantageCollector) typeExcludedSkills(ctx context.Context) map[string]bool {
+	if c.skillTypes == nil {
+		return nil
+	}
+	if _, err := c.cachedGetSkills(ctx); err != nil {
+		log.Printf("Not filtering transactions by skill type, failed to get skills: %v", err)
+		return nil
+	}
+	c.skillsMu.RLock()
+	defer c.skillsMu.RUnlock()
+	return c.typeExcluded
+}
+
+// filteredCollector collects a tenant with a scrape-time skill filter applied
+type filteredCollector struct {
+	collector *vantageCollector
+	filter    skillFilter
+}
+
+func (f filteredCollector) Describe(ch chan<- *prometheus.Desc) {
+	f.collector.Describe(ch)
+}
+
+func (f filteredCollector) Collect(ch chan<- prometheus.Metric) {
+	if f.collector.collectInterval > 0 {
+		f.collector.serveSnapshot(ch, &f.filter)
+		return
+	}
+	f.collector.collect(ch, f.filter)
+}
+
+// metricsHandler serves /metrics from the Vantage registry and the shared
+// runtime and build metrics. A skills query parameter restricts the Vantage
+// series to those skills for this scrape only; the shared series are served
+// either way.
+func metricsHandler(registry *prometheus.Registry, shared prometheus.Gatherer, collectors []*vantageCollector) http.Handler {
+	// OpenMetrics is offered so scrapes that ask for it get exemplars
+	opts := promhttp.HandlerOpts{EnableOpenMetrics: true}
+	defaultHandler := promhttp.HandlerFor(prometheus.Gatherers{registry, shared}, opts)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		param := r.URL.Query().Get("skills")
+		if param == "" {
+			defaultHandler.ServeHTTP(w, r)
+			return
+		}
+		ids, err := parseSkillIDs(param)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		filtered := prometheus.NewRegistry()
+		for _, c := range collectors {
+			filtered.MustRegister(filteredCollector{collector: c, filter: c.skillFilter.narrow(ids)})
+		}
+		promhttp.HandlerFor(prometheus.Gatherers{filtered, shared}, opts).ServeHTTP(w, r)
+	})
+}
+
+// parseSkillIDs parses a skills query parameter in either the plain a,b,c or
+// Grafana {a,b,c} multi-value format into unique skill IDs, preserving the
+// order they were given in
+func parseSkillIDs(param string) ([]string, error) {
+	if strings.TrimSpace(param) == "" {
+		return nil, errors.New("skills parameter required (e.g., ?skills=skill1,skill2,skill3)")
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range splitList(param) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no valid skill IDs provided")
+	}
+	return ids, nil
+}
+
+// splitList splits a comma-separated list, trimming whitespace and Grafana's
+// {a,b} braces and dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(strings.Trim(strings.TrimSpace(value), "{}"), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func toSet(items []string) map[string]bool {
+	set := make(map[string]bool, len(items))
+	for _, item := range items {
+		set[item] = true
+	}
+	return set
+}
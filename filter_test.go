@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSkillTypeFilter(t *testing.T) {
+	t.Setenv("VANTAGE_SKILL_TYPES", "document")
+	c := newTestCollector(t, fakeAPI{
+		"skills": respondJSON(t, []Skill{
+			{ID: "s1", Name: "Invoice", Type: "Document"},
+			{ID: "s2", Name: "Receipt", Type: "Classification"},
+		}),
+		"completed": respondJSON(t, transactionList(
+			Transaction{ID: "c1", SkillID: "s1", Status: "Finished Successfully"},
+			Transaction{ID: "c2", SkillID: "s2", Status: "Finished Successfully"},
+		)),
+	})
+
+	compareMetrics(t, c, `
+# HELP vantage_skill_info Vantage skill information
+# TYPE vantage_skill_info gauge
+vantage_skill_info{skill_id="s1",skill_name="Invoice",skill_type="Document",tenant="test"} 1
+# HELP vantage_completed_transactions_total Completed transactions seen since the exporter started by skill, raw status (other when outside VANTAGE_COUNTED_STATUSES) and normalized status category. Each transaction is counted once across scrapes
+# TYPE vantage_completed_transactions_total counter
+vantage_completed_transactions_total{category="success",skill_id="s1",status="Finished Successfully",tenant="test"} 1
+`, "vantage_skill_info", "vantage_completed_transactions_total")
+}
+
+func TestSkillTypeFilterCachesEmptyList(t *testing.T) {
+	t.Setenv("VANTAGE_SKILL_TYPES", "Document")
+	var fetches atomic.Int32
+	skills := respondJSON(t, []Skill{{ID: "s2", Name: "Receipt", Type: "Classification"}})
+	c := newTestCollector(t, fakeAPI{
+		"skills": func(w http.ResponseWriter, r *http.Request) {
+			fetches.Add(1)
+			skills(w, r)
+		},
+	})
+
+	for i := 0; i < 3; i++ {
+		if n := testutil.CollectAndCount(c, "vantage_skill_info"); n != 0 {
+			t.Fatalf("got %d skill_info series, want 0", n)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("skills API fetched %d times, want 1 within the cache TTL", n)
+	}
+}
+
+func TestParseSkillIDs(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		param   string
+		want    []string
+		wantErr bool
+	}{
+		{"single", "s1", []string{"s1"}, false},
+		{"list", "s1,s2,s3", []string{"s1", "s2", "s3"}, false},
+		{"grafana braces", "{s1,s2}", []string{"s1", "s2"}, false},
+		{"single in braces", "{s1}", []string{"s1"}, false},
+		{"whitespace", "  s1 , s2 ,s3  ", []string{"s1", "s2", "s3"}, false},
+		{"whitespace around braces", " { s1, s2 } ", []string{"s1", "s2"}, false},
+		{"duplicates", "s1,s2,s1,s2", []string{"s1", "s2"}, false},
+		{"empty entries", "s1,,s2,", []string{"s1", "s2"}, false},
+		{"empty", "", nil, true},
+		{"blank", "   ", nil, true},
+		{"empty braces", "{}", nil, true},
+		{"only commas", " , ,", nil, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseSkillIDs(tc.param)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseSkillIDs(%q) error = %v, want error %v", tc.param, err, tc.wantErr)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("parseSkillIDs(%q) = %q, want %q", tc.param, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+)
+
+// SkillHealth is the rolled-up health of one skill as served by /skill-health.
+// Rates and the average are null when no completed transaction supports them.
+type SkillHealth struct {
+	SkillID              string   `json:"skill_id"`
+	SkillName            string   `json:"skill_name"`
+	Completed            int      `json:"completed"`
+	SuccessRate          *float64 `json:"success_rate"`
+	FailureRate          *float64 `json:"failure_rate"`
+	Active               int      `json:"active"`
+	AvgProcessingSeconds *float64 `json:"avg_processing_seconds"`
+}
+
+// handleSkillHealth serves, per skill, the success and failure rates of its
+// completed transactions, how many are active and their average processing
+// time. The skills parameter optionally narrows the skills.
+func (c *vantageCollector) handleSkillHealth(w http.ResponseWriter, r *http.Request) {
+	filter := c.skillFilter
+	if param := r.URL.Query().Get("skills"); param != "" {
+		skillIDs, err := parseSkillIDs(param)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filter = filter.narrow(skillIDs)
+	}
+
+	skills, err := c.cachedGetSkills(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get skills: %v", err), http.StatusBadGateway)
+		return
+	}
+	active, err := c.getActiveTransactions(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get active transactions: %v", err), http.StatusBadGateway)
+		return
+	}
+	completed, err := c.getCompletedTransactions(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get completed transactions: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	type totals struct {
+		active, completed, success, failed int
+		processing                         processingAverage
+	}
+	bySkill := make(map[string]*totals)
+	for _, skill := range filter.skills(skills) {
+		bySkill[skill.ID] = &totals{}
+	}
+	for _, tx := range active {
+		if t := bySkill[tx.SkillID]; t != nil {
+			t.active++
+		}
+	}
+	for _, tx := range completed {
+		t := bySkill[tx.SkillID]
+		if t == nil {
+			continue
+		}
+		t.completed++
+		switch c.statuses.classify(tx.Status) {
+		case statusSuccess:
+			t.success++
+		case statusFailed:
+			t.failed++
+		}
+		t.processing.add(tx)
+	}
+
+	ratio := func(n, total int) *float64 {
+		if total == 0 {
+			return nil
+		}
+		v := float64(n) / float64(total)
+		return &v
+	}
+
+	results := []SkillHealth{}
+	for _, skill := range filter.skills(skills) {
+		t := bySkill[skill.ID]
+		health := SkillHealth{
+			SkillID:     skill.ID,
+			SkillName:   skill.Name,
+			Completed:   t.completed,
+			SuccessRate: ratio(t.success, t.completed),
+			FailureRate: ratio(t.failed, t.completed),
+			Active:      t.active,
+		}
+		if avg, ok := t.processing.mean(); ok {
+			health.AvgProcessingSeconds = &avg
+		}
+		results = append(results, health)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].SkillID < results[j].SkillID })
+
+	writeJSON(w, http.StatusOK, results)
+	log.Printf("Returned health for %d skills", len(results))
+}
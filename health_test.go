@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestAverageProcessingAgrees checks that /skill-health, /transaction-details
+// and vantage_avg_processing_seconds apply the same rule to zero, negative
+// and missing durations
+func TestAverageProcessingAgrees(t *testing.T) {
+	c := newTestCollector(t, fakeAPI{
+		"skills": respondJSON(t, []Skill{{ID: "s1", Name: "Invoice"}}),
+		"completed": respondJSON(t, transactionList(
+			Transaction{ID: "c1", SkillID: "s1", Status: "Failed", CreateTimeUtc: "2026-10-14T10:00:00Z", CompletedUtc: "2026-10-14T10:02:00Z"},
+			Transaction{ID: "c2", SkillID: "s1", Status: "Failed", CreateTimeUtc: "2026-10-14T10:00:00Z", CompletedUtc: "2026-10-14T10:00:00Z"},
+			Transaction{ID: "c3", SkillID: "s1", Status: "Failed", CreateTimeUtc: "2026-10-14T10:00:00Z", CompletedUtc: "2026-10-14T09:00:00Z"},
+			Transaction{ID: "c4", SkillID: "s1", Status: "Failed", CreateTimeUtc: "2026-10-14T10:00:00Z"},
+		)),
+	})
+	// c1 and c2 count; c3 completed before it was created and c4 has no
+	// completion time
+	const want, wantSamples = 60.0, 2
+
+	rec := httptest.NewRecorder()
+	c.handleSkillHealth(rec, httptest.NewRequest(http.MethodGet, "/skill-health", nil))
+	var health []SkillHealth
+	if err := json.Unmarshal(rec.Body.Bytes(), &health); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	if len(health) != 1 || health[0].AvgProcessingSeconds == nil || *health[0].AvgProcessingSeconds != want {
+		t.Errorf("/skill-health = %s, want avg_processing_seconds %v", rec.Body, want)
+	}
+
+	rec = httptest.NewRecorder()
+	c.handleTransactionDetails(rec, httptest.NewRequest(http.MethodGet, "/transaction-details?skills=s1", nil))
+	var details TransactionDetailsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &details); err != nil {
+		t.Fatalf("decoding %s: %v", rec.Body, err)
+	}
+	if len(details.Data) != 1 || details.Data[0].AverageProcessing != want || details.Data[0].ProcessingSamples != wantSamples {
+		t.Errorf("/transaction-details = %s, want avg_processing_seconds %v over %d samples", rec.Body, want, wantSamples)
+	}
+
+	compareMetrics(t, c, `
+# HELP vantage_avg_processing_seconds Average time from creation to completion of the completed transactions in this scrape by skill. Transactions missing either timestamp or completed before they were created are excluded
+# TYPE vantage_avg_processing_seconds gauge
+vantage_avg_processing_seconds{skill_id="s1",tenant="test"} 60
+# HELP vantage_avg_processing_samples Completed transactions in this scrape that vantage_avg_processing_seconds averages, by skill
+# TYPE vantage_avg_processing_samples gauge
+vantage_avg_processing_samples{skill_id="s1",tenant="test"} 2
+`, "vantage_avg_processing_seconds", "vantage_avg_processing_samples")
+}
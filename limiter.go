@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// errThrottled is returned when an outbound call could not get a slot from
+// the limiter within the queue timeout
+var errThrottled = errors.New("Vantage API request throttled by the exporter's concurrency or rate limit")
+
+// apiLimiter bounds the outbound Vantage calls of one tenant, both in
+// concurrency and in rate. Callers queue for up to queueTimeout and then
+// fail rather than piling up behind a slow API.
+type apiLimiter struct {
+	slots        chan struct{} // nil when concurrency is unlimited
+	rate         *rate.Limiter // nil when the rate is unlimited
+	queueTimeout time.Duration
+}
+
+func newAPILimiter(concurrency int, requestsPerSecond float64, burst int, queueTimeout time.Duration) *apiLimiter {
+	l := &apiLimiter{queueTimeout: queueTimeout}
+	if concurrency > 0 {
+		l.slots = make(chan struct{}, concurrency)
+	}
+	if requestsPerSecond > 0 {
+		l.rate = rate.NewLimiter(rate.Limit(requestsPerSecond), max(burst, 1))
+	}
+	return l
+}
+
+// acquire waits for a request slot. The returned release must be called
+// once the response body is done with.
+func (l *apiLimiter) acquire(parent context.Context) (func(), error) {
+	ctx, cancel := context.WithTimeout(parent, l.queueTimeout)
+	defer cancel()
+
+	// Report the caller's own deadline as such rather than as throttling
+	throttled := func() error {
+		if err := parent.Err(); err != nil {
+			return err
+		}
+		return errThrottled
+	}
+
+	if l.rate != nil {
+		if err := l.rate.Wait(ctx); err != nil {
+			return nil, throttled()
+		}
+	}
+	if l.slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return func() { <-l.slots }, nil
+	case <-ctx.Done():
+		return nil, throttled()
+	}
+}
+
+// releasingBody frees the limiter slot when the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	if b.release != nil {
+		b.release()
+		b.release = nil
+	}
+	return err
+}
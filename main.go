@@ -2,18 +2,31 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/sync/singleflight"
 )
 
 // Skill represents a Vantage skill
@@ -36,6 +49,25 @@ type StageDto struct {
 	Name string `json:"name"`
 }
 
+// UnmarshalJSON accepts the {type, name} object as well as the plain stage
+// name string some API versions return instead
+func (s *StageDto) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*s = StageDto{Name: name}
+		return nil
+	}
+
+	// A distinct type keeps json.Unmarshal from calling this method again
+	type stageObject StageDto
+	var obj stageObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	*s = StageDto(obj)
+	return nil
+}
+
 // DocumentBusinessRulesErrorDto represents business rule errors
 type DocumentBusinessRulesErrorDto struct {
 	Message string `json:"message"`
@@ -83,11 +115,47 @@ type Transaction struct {
 	TransactionParameters     []Parameter `json:"transactionParameters"`
 	FileParameters            []Parameter `json:"fileParameters"`
 	Error                     string      `json:"error,omitempty"`
-	Stage                     StageDto    `json:"stage,omitempty"`
+	Stage                     *StageDto   `json:"stage,omitempty"`
 	ManualReviewOperatorName  string      `json:"manualReviewOperatorName,omitempty"`
 	ManualReviewOperatorEmail string      `json:"manualReviewOperatorEmail,omitempty"`
 }
 
+// unknownStage labels the stage of a transaction that reports none
+const unknownStage = "unknown"
+
+// stage returns the transaction's stage name and type, either of which is
+// unknownStage when missing
+func (tx Transaction) stage() (name, stageType string) {
+	name, stageType = unknownStage, unknownStage
+	if tx.Stage == nil {
+		return name, stageType
+	}
+	if n := strings.TrimSpace(tx.Stage.Name); n != "" {
+		name = n
+	}
+	if t := strings.TrimSpace(tx.Stage.Type); t != "" {
+		stageType = t
+	}
+	return name, stageType
+}
+
+// operator returns the manual review operator's name, falling back to their
+// email, or "" when no operator has picked the transaction up
+func (tx Transaction) operator() string {
+	if name := strings.TrimSpace(tx.ManualReviewOperatorName); name != "" {
+		return name
+	}
+	return strings.TrimSpace(tx.ManualReviewOperatorEmail)
+}
+
+// normalize drops an empty stage object so it is omitted when the
+// transaction is marshaled again
+func (tx *Transaction) normalize() {
+	if tx.Stage != nil && strings.TrimSpace(tx.Stage.Name) == "" && strings.TrimSpace(tx.Stage.Type) == "" {
+		tx.Stage = nil
+	}
+}
+
 // TransactionResponse represents the API response structure
 type TransactionResponse struct {
 	Items          []Transaction `json:"items"`
@@ -105,15 +173,51 @@ type TransactionMetrics struct {
 	ActiveManualReview  int            `json:"active_manual_review"`
 	AveragePages        float64        `json:"avg_pages_per_transaction"`
 	AverageDocuments    float64        `json:"avg_documents_per_transaction"`
+	AverageProcessing   float64        `json:"avg_processing_seconds"`  // over ProcessingSamples
+	ProcessingSamples   int            `json:"processing_time_samples"` // completed with both timestamps
 	BusinessRulesErrors int            `json:"business_rules_errors_total"`
-	StageBreakdown      map[string]int `json:"stage_breakdown"`
+	StageNameBreakdown  map[string]int `json:"stage_name_breakdown"`
+	StageTypeBreakdown  map[string]int `json:"stage_type_breakdown"`
 	StatusBreakdown     map[string]int `json:"status_breakdown"`
 	FileTypeBreakdown   map[string]int `json:"file_type_breakdown"`
 }
 
+// transactionDetailsSchemaVersion is bumped whenever the shape of the
+// /transaction-details response changes incompatibly
+const transactionDetailsSchemaVersion = 1
+
+// TransactionDetailsResponse is the envelope returned by /transaction-details
+type TransactionDetailsResponse struct {
+	SchemaVersion int                  `json:"schema_version"`
+	Pagination    *Pagination          `json:"pagination,omitempty"`
+	Data          []TransactionMetrics `json:"data"`
+	Errors        []ResponseError      `json:"errors"`
+}
+
+// Pagination reports the per-skill transaction window applied to a
+// /transaction-details response. A zero limit means no limit.
+type Pagination struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// cachedDetails is a /transaction-details response kept for reuse
+type cachedDetails struct {
+	response   TransactionDetailsResponse
+	fetched    time.Time
+	refreshing bool
+}
+
+// ResponseError describes an upstream or request failure in a JSON response
+type ResponseError struct {
+	Source  string `json:"source"`
+	Message string `json:"message"`
+}
+
 // TokenResponse represents OAuth2 token response
 type TokenResponse struct {
 	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
 }
 
 // VantageCollector implements prometheus.Collector
@@ -121,421 +225,2233 @@ type vantageCollector struct {
 	skillMetric                    *prometheus.Desc
 	transactionMetric              *prometheus.Desc
 	completedTransactionMetric     *prometheus.Desc
+	failureReasonMetric            *prometheus.Desc
 	transactionCreatedMetric       *prometheus.Desc
 	transactionPageCountMetric     *prometheus.Desc
 	skillVersionMetric             *prometheus.Desc
 	transactionFileCountMetric     *prometheus.Desc
 	transactionDocumentCountMetric *prometheus.Desc
+	completedPageCountMetric       *prometheus.Desc
+	completedDocumentCountMetric   *prometheus.Desc
 	businessRulesErrorsMetric      *prometheus.Desc
 	resultFileTypesMetric          *prometheus.Desc
+	ruleErrorsBySkillMetric        *prometheus.Desc
+	fileTypesBySkillMetric         *prometheus.Desc
+	sourceFileTypesMetric          *prometheus.Desc
+	detailFetchesMetric            *prometheus.Desc
 	processingSuccessMetric        *prometheus.Desc
-
+	processingDurationMetric       *prometheus.Desc
+	activeTransactionAgeMetric     *prometheus.Desc
+	activeProcessingMetric         *prometheus.Desc
+	activeManualReviewMetric       *prometheus.Desc
+	manualReviewAssignedMetric     *prometheus.Desc
+	activeByStageMetric            *prometheus.Desc
+	avgPagesMetric                 *prometheus.Desc
+	avgDocumentsMetric             *prometheus.Desc
+	avgProcessingMetric            *prometheus.Desc
+	avgProcessingSamplesMetric     *prometheus.Desc
+	pagesProcessedMetric           *prometheus.Desc
+	documentsProcessedMetric       *prometheus.Desc
+	activeTotalMetric              *prometheus.Desc
+	completedAvailableMetric       *prometheus.Desc
+
+	self *selfMetrics
+	// disabled holds the Descs named in VANTAGE_DISABLED_METRICS, which are
+	// neither described nor collected
+	disabled map[*prometheus.Desc]bool
+
+	// With a collectInterval, scrapes are served the snapshot collected in
+	// the background rather than calling the API themselves
+	collectInterval time.Duration
+	snapshotMu      sync.RWMutex
+	snapshot        []prometheus.Metric
+	snapshotTime    time.Time
+
+	tenant       string
 	baseURL      string
 	clientID     string
 	clientSecret string
-	port         string
-
+	oauthScope   string
+	tokenPath    []string // path segments of the token endpoint
+	authMode     string
+	apiKey       string
+	apiKeyHeader string
+	maxPages     int
+	pageLimit    int
+
+	maxDetailSkills int
+	lookback        time.Duration
+
+	durationBuckets []float64
+	nativeFactor    float64 // native histogram bucket factor, 0 for classic only
+	readyStaleness  time.Duration
+	scrapeTimeout   time.Duration
+	httpTimeout     time.Duration
+	detailTimeout   time.Duration
+	proxyURL        string
+	userAgent       string
+	caCertFile      string
+	tlsInsecure     bool
+
+	enableDetailMetrics bool
+	detailConcurrency   int
+	skillConcurrency    int
+	detailMax           int
+	perTransaction      bool
+	perCompleted        bool // also per completed transaction, needs perTransaction
+	statuses            statusClassifier
+	failureReasons      failureClassifier
+	countedStatuses     map[string]bool // nil counts every status as is
+	skillNameMode       string
+	skillFilter         skillFilter
+
+	httpClient    *http.Client
+	limiter       *apiLimiter
+	breaker       *circuitBreaker
+	maxRetryAfter time.Duration
+	// maxResponseSize caps how many bytes of a response body are decoded
+	maxResponseSize int64
+	// correlationHeader carries an ID generated for every outbound request,
+	// empty sending none
+	correlationHeader string
+
+	detailsMu       sync.Mutex
+	detailsCacheTTL time.Duration
+	detailsCache    map[string]*cachedDetails
+
+	skillsMu        sync.RWMutex
+	skillsCacheTTL  time.Duration
 	cachedSkills    []Skill
 	skillsCacheTime time.Time
+	// skillTypes restricts collection to skills of these lowercased types,
+	// nil collecting every type. typeExcluded holds the IDs of the skills
+	// the last refresh dropped for their type.
+	skillTypes   map[string]bool
+	typeExcluded map[string]bool
+
+	tokenMu     sync.Mutex
+	token       string
+	tokenExpiry time.Time
+	tokenFlight singleflight.Group
+
+	healthMu          sync.Mutex
+	lastTokenSuccess  time.Time
+	lastScrapeSuccess time.Time
+
+	// Running totals over every completed transaction seen since the
+	// exporter started. Volumes are keyed by skill ID, completions by skill
+	// ID, counted status and category, failures by skill ID and reason,
+	// business rule errors by skill ID and error type, result and source files
+	// by skill ID and file type.
+	seenCompleted      *seenSet
+	totalsMu           sync.Mutex
+	completedCounts    map[[3]string]int
+	failedExemplars    map[[3]string]prometheus.Exemplar
+	failureCounts      map[[2]string]int
+	seenDetails        *seenSet
+	ruleErrorCounts    map[[2]string]int
+	fileTypeCounts     map[[2]string]int
+	sourceTypeCounts   map[[2]string]int
+	pagesProcessed     map[string]int
+	documentsProcessed map[string]int
+
+	// processingDurations observes each completed transaction's processing
+	// time once, when it is first counted
+	processingDurations *prometheus.HistogramVec
+
+	versionsMu    sync.Mutex
+	skillVersions map[string]int
+
+	// With completedCursor, scrapes only request completions after cursor,
+	// the newest completion seen so far
+	completedCursor bool
+	cursorMu        sync.Mutex
+	cursor          time.Time
+
+	// listTotals holds the TotalItemCount last reported by each transaction
+	// list, which can exceed what maxPages lets us fetch
+	listTotalsMu sync.Mutex
+	listTotals   map[string]int
+
+	// recent holds the latest state of every transaction fetched by a list
+	// call, whether from a scrape or an HTTP handler
+	recent *transactionStore
+	// details caches the detail of finished transactions
+	details *detailCache
+}
+
+// perTransactionHelp is appended to the help of every metric labeled by
+// transaction_id, since those create a series per transaction
+const perTransactionHelp = ". One series per transaction, which churns quickly on busy tenants; " +
+	"set VANTAGE_DISABLE_PER_TRANSACTION to keep only skill-level aggregates"
+
+// defaultOAuthScope is requested when VANTAGE_OAUTH_SCOPE is unset
+const defaultOAuthScope = "global.wildcard openid permissions"
+
+// The ways of authenticating to the Vantage API: OAuth2 client credentials
+// exchanged for a bearer token, or a static API key sent in a header
+const (
+	authModeOAuth2 = "oauth2"
+	authModeAPIKey = "apikey"
+)
+
+// defaultTokenPath is the Vantage identity endpoint, relative to the base URL
+const defaultTokenPath = "/auth2/connect/token"
+
+const (
+	// defaultTokenLifetime is used when the token response has no expires_in
+	defaultTokenLifetime = 300 * time.Second
+	// tokenRefreshMargin refreshes the token this long before it expires
+	tokenRefreshMargin = 60 * time.Second
+)
+
+// selfMetrics tracks the exporter's own health. They are shared by every
+// tenant's collector and registered once as regular collectors.
+type selfMetrics struct {
+	scrapeErrors   *prometheus.CounterVec
+	scrapeDuration *prometheus.GaugeVec
+	lastSuccess    *prometheus.GaugeVec
+	circuitOpen    *prometheus.GaugeVec
+	apiThrottled   *prometheus.CounterVec
+	apiRateLimited *prometheus.CounterVec
+	apiRequests    *prometheus.CounterVec
+	apiDuration    *prometheus.HistogramVec
+	detailCache    *prometheus.CounterVec
+	pushFailures   prometheus.Counter
+	schemaWarnings *prometheus.CounterVec
+}
+
+func newSelfMetrics() *selfMetrics {
+	return &selfMetrics{
+		scrapeErrors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "vantage_scrape_errors_total",
+				Help: "Total failed Vantage API fetches by endpoint",
+			},
+			[]string{"tenant", "endpoint"},
+		),
+		scrapeDuration: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "vantage_scrape_duration_seconds",
+				Help: "Duration of the last Vantage API fetch by endpoint",
+			},
+			[]string{"tenant", "endpoint"},
+		),
+		lastSuccess: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "vantage_last_successful_scrape_timestamp_seconds",
+				Help: "Unix time of the last successful Vantage API fetch by endpoint",
+			},
+			[]string{"tenant", "endpoint"},
+		),
+		circuitOpen: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "vantage_circuit_open",
+				Help: "Whether calls to a Vantage API endpoint are failing fast after consecutive failures (1) or being sent (0)",
+			},
+			[]string{"tenant", "endpoint"},
+		),
+		apiThrottled: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "vantage_api_throttled_total",
+				Help: "Outbound Vantage API requests rejected by the exporter's own concurrency or rate limit",
+			},
+			[]string{"tenant"},
+		),
+		apiRateLimited: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "vantage_api_rate_limited_total",
+				Help: "Vantage API responses with status 429 Too Many Requests",
+			},
+			[]string{"tenant"},
+		),
+		apiRequests: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "vantage_api_requests_total",
+				Help: "Vantage API requests by endpoint and response status code, or \"error\" when no response was received",
+			},
+			[]string{"tenant", "endpoint", "status_code"},
+		),
+		apiDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "vantage_api_request_duration_seconds",
+				Help:    "Latency of outbound Vantage API requests by endpoint, excluding time queued by the exporter's limiter",
+				Buckets: prometheus.DefBuckets,
+
+				NativeHistogramBucketFactor:     nativeHistogramFactor(),
+				NativeHistogramMaxBucketNumber:  160,
+				NativeHistogramMinResetDuration: time.Hour,
+			},
+			[]string{"tenant", "endpoint"},
+		),
+		detailCache: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "vantage_detail_cache_requests_total",
+				Help: "Transaction detail lookups by whether they were served from the detail cache (hit) or the API (miss)",
+			},
+			[]string{"tenant", "result"},
+		),
+		schemaWarnings: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "vantage_schema_warnings_total",
+				Help: "Vantage API responses in which an expected field was empty in every item, a sign of API schema drift",
+			},
+			[]string{"tenant", "endpoint", "field"},
+		),
+		pushFailures: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "vantage_push_failures_total",
+				Help: "Failed pushes to the Pushgateway set in VANTAGE_PUSHGATEWAY_URL",
+			},
+		),
+	}
+}
+
+func (m *selfMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.scrapeErrors, m.scrapeDuration, m.lastSuccess, m.circuitOpen, m.apiThrottled, m.apiRateLimited, m.apiRequests, m.apiDuration, m.detailCache, m.pushFailures, m.schemaWarnings}
+}
+
+// cacheMetrics reports the size of the tenant's in-memory caches. They are
+// exporter health rather than Vantage data, so they go with the self metrics.
+func (c *vantageCollector) cacheMetrics() []prometheus.Collector {
+	constLabels := prometheus.Labels{"tenant": c.tenant}
+	return []prometheus.Collector{
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name:        "vantage_transaction_cache_size",
+				Help:        "Transactions held in the in-memory store of recently fetched transactions",
+				ConstLabels: constLabels,
+			},
+			func() float64 { return float64(c.recent.len()) },
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name:        "vantage_detail_cache_size",
+				Help:        "Finished transaction details held in the in-memory detail cache",
+				ConstLabels: constLabels,
+			},
+			func() float64 { return float64(c.details.len()) },
+		),
+		prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name:        "vantage_snapshot_age_seconds",
+				Help:        "Age of the metrics snapshot collected in the background with VANTAGE_COLLECT_INTERVAL, 0 before the first one or without it",
+				ConstLabels: constLabels,
+			},
+			c.snapshotAge,
+		),
+	}
 }
 
-func newVantageCollector() *vantageCollector {
-	return &vantageCollector{
-		skillMetric: prometheus.NewDesc(
+func newVantageCollector(tenant tenantConfig, self *selfMetrics) *vantageCollector {
+	// Every series carries the tenant so several tenants can share a registry
+	constLabels := prometheus.Labels{"tenant": tenant.Name}
+
+	// Remember each metric's name so VANTAGE_DISABLED_METRICS can refer to it
+	descNames := make(map[*prometheus.Desc]string)
+	newDesc := func(name, help string, variableLabels []string, constLabels prometheus.Labels) *prometheus.Desc {
+		desc := prometheus.NewDesc(name, help, variableLabels, constLabels)
+		descNames[desc] = name
+		return desc
+	}
+
+	c := &vantageCollector{
+		skillMetric: newDesc(
 			"vantage_skill_info",
 			"Vantage skill information",
-			[]string{"skill_id", "skill_name", "skill_type"}, nil,
+			[]string{"skill_id", "skill_name", "skill_type"}, constLabels,
 		),
-		transactionMetric: prometheus.NewDesc(
+		transactionMetric: newDesc(
 			"vantage_active_transaction",
-			"Vantage active transaction",
-			[]string{"transaction_id", "skill_id"}, nil,
+			"Vantage active transaction"+perTransactionHelp,
+			[]string{"transaction_id", "skill_id"}, constLabels,
 		),
-		completedTransactionMetric: prometheus.NewDesc(
+		completedTransactionMetric: newDesc(
 			"vantage_completed_transactions_total",
-			"Total completed transactions by skill and status",
-			[]string{"skill_id", "status"}, nil,
+			"Completed transactions seen since the exporter started by skill, raw status (other when outside VANTAGE_COUNTED_STATUSES) and normalized status category. Each transaction is counted once across scrapes",
+			[]string{"skill_id", "status", "category"}, constLabels,
 		),
-		transactionCreatedMetric: prometheus.NewDesc(
+		failureReasonMetric: newDesc(
+			"vantage_failed_transactions_by_reason_total",
+			"Failed transactions seen since the exporter started by skill and reason, classified from the transaction error text (other when no pattern matches, unknown when empty)",
+			[]string{"skill_id", "reason"}, constLabels,
+		),
+		transactionCreatedMetric: newDesc(
 			"vantage_transaction_created_timestamp",
-			"Transaction creation timestamp",
-			[]string{"skill_id", "transaction_id"}, nil,
+			"Unix time at which an active transaction was created"+perTransactionHelp,
+			[]string{"skill_id", "transaction_id"}, constLabels,
 		),
-		transactionPageCountMetric: prometheus.NewDesc(
+		transactionPageCountMetric: newDesc(
 			"vantage_transaction_page_count",
-			"Number of pages per transaction",
-			[]string{"skill_id", "transaction_id"}, nil,
+			"Number of pages per transaction"+perTransactionHelp,
+			[]string{"skill_id", "transaction_id"}, constLabels,
 		),
-		skillVersionMetric: prometheus.NewDesc(
-			"vantage_skill_version",
-			"Skill version used for transaction",
-			[]string{"skill_id", "version"}, nil,
+		skillVersionMetric: newDesc(
+			"vantage_skill_version_info",
+			"Newest skill version seen in a transaction, per known skill",
+			[]string{"skill_id", "skill_name", "version"}, constLabels,
 		),
-		transactionFileCountMetric: prometheus.NewDesc(
+		transactionFileCountMetric: newDesc(
 			"vantage_transaction_file_count",
-			"Number of source files per transaction",
-			[]string{"skill_id", "transaction_id"}, nil,
+			"Number of source files submitted per transaction"+perTransactionHelp,
+			[]string{"skill_id", "transaction_id"}, constLabels,
 		),
-		transactionDocumentCountMetric: prometheus.NewDesc(
+		transactionDocumentCountMetric: newDesc(
 			"vantage_transaction_document_count",
-			"Number of extracted documents per transaction",
-			[]string{"skill_id", "transaction_id"}, nil,
+			"Number of extracted documents per transaction"+perTransactionHelp,
+			[]string{"skill_id", "transaction_id"}, constLabels,
+		),
+		completedPageCountMetric: newDesc(
+			"vantage_completed_transaction_page_count",
+			"Number of pages per completed transaction in the fetched window, with VANTAGE_PER_COMPLETED"+perTransactionHelp,
+			[]string{"skill_id", "transaction_id"}, constLabels,
 		),
-		businessRulesErrorsMetric: prometheus.NewDesc(
+		completedDocumentCountMetric: newDesc(
+			"vantage_completed_transaction_document_count",
+			"Number of documents per completed transaction in the fetched window, with VANTAGE_PER_COMPLETED"+perTransactionHelp,
+			[]string{"skill_id", "transaction_id"}, constLabels,
+		),
+		businessRulesErrorsMetric: newDesc(
 			"vantage_business_rules_errors_total",
-			"Business rule validation errors per transaction",
-			[]string{"skill_id", "transaction_id", "error_type"}, nil,
+			"Business rule validation errors per transaction"+perTransactionHelp,
+			[]string{"skill_id", "transaction_id", "error_type"}, constLabels,
 		),
-		resultFileTypesMetric: prometheus.NewDesc(
+		resultFileTypesMetric: newDesc(
 			"vantage_result_file_types_total",
-			"Types of result files generated per transaction",
-			[]string{"skill_id", "transaction_id", "file_type"}, nil,
+			"Types of result files generated per transaction"+perTransactionHelp,
+			[]string{"skill_id", "transaction_id", "file_type"}, constLabels,
+		),
+		ruleErrorsBySkillMetric: newDesc(
+			"vantage_business_rules_errors_by_skill_total",
+			"Business rule validation errors in completed transactions whose detail was fetched since the exporter started, by skill and error type",
+			[]string{"skill_id", "error_type"}, constLabels,
 		),
-		processingSuccessMetric: prometheus.NewDesc(
+		fileTypesBySkillMetric: newDesc(
+			"vantage_result_file_types_by_skill_total",
+			"Result files in completed transactions whose detail was fetched since the exporter started, by skill and file type",
+			[]string{"skill_id", "file_type"}, constLabels,
+		),
+		sourceFileTypesMetric: newDesc(
+			"vantage_source_file_types_total",
+			"Source files in completed transactions whose detail was fetched since the exporter started, by skill and lowercased file name extension (\"none\" without one)",
+			[]string{"skill_id", "file_type"}, constLabels,
+		),
+		detailFetchesMetric: newDesc(
+			"vantage_detail_fetches",
+			"Completed transactions by outcome of their detail fetch in the last scrape: fetched, failed, skipped as already counted or held in the detail cache, or capped by VANTAGE_DETAIL_MAX",
+			[]string{"outcome"}, constLabels,
+		),
+		processingSuccessMetric: newDesc(
 			"vantage_processing_success",
-			"Transaction processing success indicator",
-			[]string{"skill_id", "transaction_id", "status"}, nil,
+			"Transaction processing success indicator"+perTransactionHelp,
+			[]string{"skill_id", "transaction_id", "status"}, constLabels,
+		),
+		processingDurationMetric: newDesc(
+			processingDurationName,
+			processingDurationHelp,
+			[]string{"skill_id"}, constLabels,
+		),
+		activeTransactionAgeMetric: newDesc(
+			"vantage_active_transaction_age_seconds",
+			"Seconds since an active transaction was created"+perTransactionHelp,
+			[]string{"skill_id", "transaction_id"}, constLabels,
+		),
+		activeProcessingMetric: newDesc(
+			"vantage_active_processing",
+			"Active transactions being processed automatically by skill",
+			[]string{"skill_id"}, constLabels,
+		),
+		activeManualReviewMetric: newDesc(
+			"vantage_active_manual_review",
+			"Active transactions assigned to manual review by skill",
+			[]string{"skill_id"}, constLabels,
+		),
+		manualReviewAssignedMetric: newDesc(
+			"vantage_manual_review_assigned",
+			"Active transactions assigned to each manual review operator",
+			[]string{"skill_id", "operator"}, constLabels,
+		),
+		activeByStageMetric: newDesc(
+			"vantage_active_transactions_by_stage",
+			"Active transactions by skill and processing stage",
+			[]string{"skill_id", "stage_name", "stage_type"}, constLabels,
+		),
+		avgPagesMetric: newDesc(
+			"vantage_avg_pages_per_transaction",
+			"Average pages per active and completed transaction by skill",
+			[]string{"skill_id"}, constLabels,
+		),
+		avgDocumentsMetric: newDesc(
+			"vantage_avg_documents_per_transaction",
+			"Average documents per active and completed transaction by skill",
+			[]string{"skill_id"}, constLabels,
+		),
+		avgProcessingMetric: newDesc(
+			"vantage_avg_processing_seconds",
+			"Average time from creation to completion of the completed transactions in this scrape by skill. Transactions missing either timestamp or completed before they were created are excluded",
+			[]string{"skill_id"}, constLabels,
 		),
+		avgProcessingSamplesMetric: newDesc(
+			"vantage_avg_processing_samples",
+			"Completed transactions in this scrape that vantage_avg_processing_seconds averages, by skill",
+			[]string{"skill_id"}, constLabels,
+		),
+		pagesProcessedMetric: newDesc(
+			"vantage_pages_processed_total",
+			"Pages in completed transactions seen since the exporter started. Each transaction is counted once across scrapes",
+			[]string{"skill_id"}, constLabels,
+		),
+		documentsProcessedMetric: newDesc(
+			"vantage_documents_processed_total",
+			"Documents in completed transactions seen since the exporter started. Each transaction is counted once across scrapes",
+			[]string{"skill_id"}, constLabels,
+		),
+		activeTotalMetric: newDesc(
+			"vantage_active_transactions_total",
+			"Active transactions as reported by the API's TotalItemCount, across all skills and regardless of VANTAGE_MAX_PAGES",
+			nil, constLabels,
+		),
+		completedAvailableMetric: newDesc(
+			"vantage_completed_transactions_available",
+			"Completed transactions within the lookback as reported by the API's TotalItemCount, across all skills and regardless of VANTAGE_MAX_PAGES",
+			nil, constLabels,
+		),
+
+		self: self,
+
+		tenant:       tenant.Name,
+		baseURL:      tenant.BaseURL,
+		clientID:     tenant.ClientID,
+		clientSecret: tenant.ClientSecret,
+		oauthScope:   strings.TrimSpace(getEnv("VANTAGE_OAUTH_SCOPE", defaultOAuthScope)),
+		tokenPath:    strings.FieldsFunc(getEnv("VANTAGE_TOKEN_PATH", defaultTokenPath), func(r rune) bool { return r == '/' }),
+		authMode:     strings.ToLower(getEnv("VANTAGE_AUTH_MODE", authModeOAuth2)),
+		apiKey:       tenant.APIKey,
+		apiKeyHeader: getEnv("VANTAGE_API_KEY_HEADER", "Authorization"),
+		maxPages:     max(getEnvInt("VANTAGE_MAX_PAGES", 10), 1),
+		pageLimit:    clampPageLimit(getEnvInt("VANTAGE_PAGE_LIMIT", defaultPageLimit)),
+
+		collectInterval: getEnvDuration("VANTAGE_COLLECT_INTERVAL", 0),
+
+		maxDetailSkills: max(getEnvInt("VANTAGE_MAX_DETAIL_SKILLS", 20), 1),
+		lookback:        getEnvDuration("VANTAGE_LOOKBACK", 0),
+		completedCursor: getEnvBool("VANTAGE_COMPLETED_CURSOR", false),
+
+		durationBuckets: getEnvFloats("VANTAGE_DURATION_BUCKETS", defaultDurationBuckets),
+		nativeFactor:    nativeHistogramFactor(),
+		readyStaleness:  getEnvDuration("VANTAGE_READY_STALENESS", 10*time.Minute),
+		scrapeTimeout:   getEnvDuration("VANTAGE_SCRAPE_TIMEOUT", 60*time.Second),
+		httpTimeout:     getEnvDuration("VANTAGE_HTTP_TIMEOUT", 30*time.Second),
+		detailTimeout:   getEnvDuration("VANTAGE_DETAIL_TIMEOUT", 10*time.Second),
+		skillsCacheTTL:  getEnvDuration("VANTAGE_SKILLS_CACHE_TTL", 5*time.Minute),
+		detailsCacheTTL: getEnvDuration("VANTAGE_DETAILS_CACHE_TTL", 30*time.Second),
+		detailsCache:    make(map[string]*cachedDetails),
+
+		enableDetailMetrics: getEnvBool("VANTAGE_ENABLE_DETAIL_METRICS", false),
+		detailConcurrency:   max(getEnvInt("VANTAGE_DETAIL_CONCURRENCY", 4), 1),
+		skillConcurrency:    max(getEnvInt("VANTAGE_SKILL_CONCURRENCY", 4), 1),
+		detailMax:           max(getEnvInt("VANTAGE_DETAIL_MAX", 200), 0),
+		perTransaction:      !getEnvBool("VANTAGE_DISABLE_PER_TRANSACTION", false),
+		perCompleted:        getEnvBool("VANTAGE_PER_COMPLETED", false),
+		statuses:            newStatusClassifier(getEnv("VANTAGE_STATUS_MAPPING", "")),
+		failureReasons:      newFailureClassifier(getEnv("VANTAGE_FAILURE_REASONS", "")),
+		countedStatuses:     newCountedStatuses(splitList(getEnv("VANTAGE_COUNTED_STATUSES", ""))),
+		skillNameMode:       parseSkillNameMode(getEnv("VANTAGE_SKILL_NAME_LABEL", skillNameRaw)),
+		skillFilter: newSkillFilter(
+			splitList(getEnv("VANTAGE_SKILL_ALLOWLIST", "")),
+			splitList(getEnv("VANTAGE_SKILL_DENYLIST", "")),
+		),
+		skillTypes: newSkillTypes(splitList(getEnv("VANTAGE_SKILL_TYPES", ""))),
+
+		proxyURL:    getEnv("VANTAGE_PROXY_URL", ""),
+		userAgent:   getEnv("VANTAGE_USER_AGENT", "vantage-exporter/"+version),
+		caCertFile:  getEnv("VANTAGE_CA_CERT", ""),
+		tlsInsecure: getEnvBool("VANTAGE_TLS_INSECURE", false),
+
+		limiter: newAPILimiter(
+			getEnvInt("VANTAGE_API_CONCURRENCY", 4),
+			getEnvFloat("VANTAGE_API_RATE", 0),
+			getEnvInt("VANTAGE_API_BURST", 1),
+			getEnvDuration("VANTAGE_API_QUEUE_TIMEOUT", 5*time.Second),
+		),
+		breaker: newCircuitBreaker(
+			getEnvInt("VANTAGE_CIRCUIT_FAILURES", 5),
+			getEnvDuration("VANTAGE_CIRCUIT_COOLDOWN", 30*time.Second),
+			func(endpoint string, open bool) {
+				if open {
+					log.Printf("Opening circuit for the %s endpoint of tenant %s after consecutive failures", endpoint, tenant.Name)
+					self.circuitOpen.WithLabelValues(tenant.Name, endpoint).Set(1)
+				} else {
+					log.Printf("Closing circuit for the %s endpoint of tenant %s", endpoint, tenant.Name)
+					self.circuitOpen.WithLabelValues(tenant.Name, endpoint).Set(0)
+				}
+			},
+		),
+		maxRetryAfter:   getEnvDuration("VANTAGE_MAX_RETRY_AFTER", 30*time.Second),
+		maxResponseSize: int64(max(getEnvInt("VANTAGE_MAX_RESPONSE_BYTES", 8<<20), 1)),
+
+		correlationHeader: correlationHeader(getEnv("VANTAGE_CORRELATION_HEADER", defaultCorrelationHeader)),
+
+		seenCompleted:      newSeenSet(getEnvInt("VANTAGE_SEEN_CACHE_SIZE", 10000)),
+		completedCounts:    make(map[[3]string]int),
+		failedExemplars:    make(map[[3]string]prometheus.Exemplar),
+		failureCounts:      make(map[[2]string]int),
+		seenDetails:        newSeenSet(getEnvInt("VANTAGE_SEEN_CACHE_SIZE", 10000)),
+		ruleErrorCounts:    make(map[[2]string]int),
+		fileTypeCounts:     make(map[[2]string]int),
+		sourceTypeCounts:   make(map[[2]string]int),
+		skillVersions:      make(map[string]int),
+		listTotals:         make(map[string]int),
+		pagesProcessed:     make(map[string]int),
+		documentsProcessed: make(map[string]int),
+
+		recent: newTransactionStore(
+			getEnvInt("VANTAGE_TRANSACTION_CACHE_SIZE", 5000),
+			getEnvDuration("VANTAGE_TRANSACTION_CACHE_TTL", time.Hour),
+		),
+		details: newDetailCache(
+			getEnvInt("VANTAGE_DETAIL_CACHE_SIZE", 10000),
+			getEnvDuration("VANTAGE_DETAIL_CACHE_TTL", 24*time.Hour),
+		),
+	}
 
-		baseURL:      getEnv("VANTAGE_BASE_URL", "https://vantage-us.abbyy.com"),
-		clientID:     getEnv("VANTAGE_CLIENT_ID", ""),
-		clientSecret: getEnv("VANTAGE_CLIENT_SECRET", ""),
-		port:         getEnv("VANTAGE_METRICS_PORT", "8080"),
+	// The observations accumulate across scrapes, and client_golang only
+	// builds native histograms by observing, so the histogram is a vec
+	// sharing its Desc's name, help and labels
+	c.processingDurations = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:        processingDurationName,
+		Help:        processingDurationHelp,
+		ConstLabels: constLabels,
+		Buckets:     c.durationBuckets,
+
+		NativeHistogramBucketFactor:     c.nativeFactor,
+		NativeHistogramMaxBucketNumber:  160,
+		NativeHistogramMinResetDuration: time.Hour,
+	}, []string{"skill_id"})
+
+	// Initialize the endpoint series so they are present before the first failure
+	for _, endpoint := range []string{"skills", "active", "completed"} {
+		self.scrapeErrors.WithLabelValues(c.tenant, endpoint)
+		self.scrapeDuration.WithLabelValues(c.tenant, endpoint)
 	}
+	c.disabled = disabledMetrics(descNames, getEnv("VANTAGE_DISABLED_METRICS", ""))
+
+	for _, endpoint := range []string{"token", "skills", "active", "completed", "detail"} {
+		self.circuitOpen.WithLabelValues(c.tenant, endpoint)
+	}
+	self.apiThrottled.WithLabelValues(c.tenant)
+	self.apiRateLimited.WithLabelValues(c.tenant)
+	for _, result := range []string{"hit", "miss"} {
+		self.detailCache.WithLabelValues(c.tenant, result)
+	}
+
+	return c
 }
 
 func (c *vantageCollector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- c.skillMetric
-	ch <- c.transactionMetric
-	ch <- c.completedTransactionMetric
-	ch <- c.transactionCreatedMetric
-	ch <- c.transactionPageCountMetric
-	ch <- c.skillVersionMetric
-	ch <- c.transactionFileCountMetric
-	ch <- c.transactionDocumentCountMetric
-	ch <- c.businessRulesErrorsMetric
-	ch <- c.resultFileTypesMetric
-	ch <- c.processingSuccessMetric
+	for _, desc := range []*prometheus.Desc{
+		c.skillMetric,
+		c.transactionMetric,
+		c.completedTransactionMetric,
+		c.failureReasonMetric,
+		c.transactionCreatedMetric,
+		c.transactionPageCountMetric,
+		c.skillVersionMetric,
+		c.transactionFileCountMetric,
+		c.transactionDocumentCountMetric,
+		c.completedPageCountMetric,
+		c.completedDocumentCountMetric,
+		c.businessRulesErrorsMetric,
+		c.resultFileTypesMetric,
+		c.ruleErrorsBySkillMetric,
+		c.fileTypesBySkillMetric,
+		c.sourceFileTypesMetric,
+		c.detailFetchesMetric,
+		c.processingSuccessMetric,
+		c.processingDurationMetric,
+		c.activeTransactionAgeMetric,
+		c.activeProcessingMetric,
+		c.activeManualReviewMetric,
+		c.manualReviewAssignedMetric,
+		c.activeByStageMetric,
+		c.avgPagesMetric,
+		c.avgDocumentsMetric,
+		c.avgProcessingMetric,
+		c.avgProcessingSamplesMetric,
+		c.pagesProcessedMetric,
+		c.documentsProcessedMetric,
+		c.activeTotalMetric,
+		c.completedAvailableMetric,
+	} {
+		if !c.disabled[desc] {
+			ch <- desc
+		}
+	}
 }
 
 func (c *vantageCollector) Collect(ch chan<- prometheus.Metric) {
-	skills, err := c.getSkills()
+	if c.collectInterval > 0 {
+		c.serveSnapshot(ch, nil)
+		return
+	}
+	c.collect(ch, c.skillFilter)
+}
+
+// collect emits metrics for the skills the filter allows. Excluded skills and
+// their transactions are dropped before any series is built.
+func (c *vantageCollector) collect(ch chan<- prometheus.Metric, filter skillFilter) {
+	if len(c.disabled) > 0 {
+		enabled, done := c.dropDisabled(ch)
+		defer done()
+		ch = enabled
+	}
+
+	// A single deadline bounds every Vantage call made during this scrape
+	ctx, cancel := context.WithTimeout(context.Background(), c.scrapeTimeout)
+	defer cancel()
+
+	start := time.Now()
+	skills, err := c.cachedGetSkills(ctx)
+	c.observeScrape("skills", start, err)
 	if err != nil {
 		log.Printf("Error getting skills: %v", err)
 	} else {
+		skills = filter.skills(skills)
 		for _, skill := range skills {
 			ch <- prometheus.MustNewConstMetric(
 				c.skillMetric,
 				prometheus.GaugeValue,
 				1,
-				skill.ID, skill.Name, skill.Type,
+				skill.ID, c.skillNameLabel(skill), skill.Type,
 			)
 		}
 	}
 
-	activeTransactions, err := c.getActiveTransactions()
+	start = time.Now()
+	activeTransactions, err := c.getActiveTransactions(ctx)
+	c.observeScrape("active", start, err)
 	if err != nil {
 		log.Printf("Error getting active transactions: %v", err)
 	} else {
+		c.collectListTotal(ch, c.activeTotalMetric, "active")
+		activeTransactions = filter.transactions(activeTransactions)
 		log.Printf("Found %d active transactions", len(activeTransactions))
 
+		// Seed known skills so idle ones report zero rather than no series
+		type activeCount struct{ processing, manualReview int }
+		activeCounts := make(map[string]*activeCount)
+		for _, skill := range skills {
+			activeCounts[skill.ID] = &activeCount{}
+		}
+		operatorCounts := make(map[string]map[string]int)
+		stageCounts := make(map[[3]string]int)
+
 		for _, tx := range activeTransactions {
+			stageName, stageType := tx.stage()
+			stageCounts[[3]string{tx.SkillID, stageName, stageType}]++
+
+			counts := activeCounts[tx.SkillID]
+			if counts == nil {
+				counts = &activeCount{}
+				activeCounts[tx.SkillID] = counts
+			}
+			if inManualReview(tx) {
+				counts.manualReview++
+
+				operator := tx.operator()
+				if operatorCounts[tx.SkillID] == nil {
+					operatorCounts[tx.SkillID] = make(map[string]int)
+				}
+				operatorCounts[tx.SkillID][operator]++
+			} else {
+				counts.processing++
+			}
+
+			if c.perTransaction {
+				c.collectActiveTransaction(ch, tx)
+			}
+		}
+
+		for skillID, counts := range activeCounts {
 			ch <- prometheus.MustNewConstMetric(
-				c.transactionMetric,
+				c.activeProcessingMetric,
 				prometheus.GaugeValue,
-				1,
-				tx.ID, tx.SkillID,
+				float64(counts.processing),
+				skillID,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				c.activeManualReviewMetric,
+				prometheus.GaugeValue,
+				float64(counts.manualReview),
+				skillID,
+			)
+		}
+
+		for key, count := range stageCounts {
+			ch <- prometheus.MustNewConstMetric(
+				c.activeByStageMetric,
+				prometheus.GaugeValue,
+				float64(count),
+				key[0], key[1], key[2],
 			)
 		}
+
+		for skillID, operators := range operatorCounts {
+			for operator, count := range operators {
+				ch <- prometheus.MustNewConstMetric(
+					c.manualReviewAssignedMetric,
+					prometheus.GaugeValue,
+					float64(count),
+					skillID, operator,
+				)
+			}
+		}
 	}
 
-	completedTransactions, err := c.getCompletedTransactions()
+	start = time.Now()
+	completedTransactions, err := c.getScrapeCompletedTransactions(ctx)
+	c.observeScrape("completed", start, err)
 	if err != nil {
 		log.Printf("Error getting completed transactions: %v", err)
 	} else {
-		statusCounts := make(map[string]map[string]int)
-		skillVersionsSeen := make(map[string]bool)
+		c.collectListTotal(ch, c.completedAvailableMetric, "completed")
+		// The running totals count every fetched completion, whatever skills
+		// this scrape emits: a filtered scrape still advances the completed
+		// cursor and marks transactions seen, so a completion it dropped
+		// would never be counted by a later scrape
+		c.countCompleted(completedTransactions)
+		completedTransactions = filter.transactions(completedTransactions)
+		averages := make(map[string]*processingAverage)
 
 		for _, tx := range completedTransactions {
 			skillID := tx.SkillID
 			status := tx.Status
 
-			if statusCounts[skillID] == nil {
-				statusCounts[skillID] = make(map[string]int)
+			if averages[skillID] == nil {
+				averages[skillID] = &processingAverage{}
 			}
-			statusCounts[skillID][status]++
+			averages[skillID].add(tx)
 
-			skillVersionKey := fmt.Sprintf("%s-%d", tx.SkillID, tx.SkillVersion)
-			if !skillVersionsSeen[skillVersionKey] {
-				skillVersionsSeen[skillVersionKey] = true
+			success := 0.0
+			if c.statuses.classify(status) == statusSuccess {
+				success = 1
+			}
+			if c.perTransaction {
 				ch <- prometheus.MustNewConstMetric(
-					c.skillVersionMetric,
+					c.processingSuccessMetric,
 					prometheus.GaugeValue,
-					1,
-					tx.SkillID, fmt.Sprintf("%d", tx.SkillVersion),
+					success,
+					tx.SkillID, tx.ID, status,
 				)
 			}
-		}
-
-		for skillID, statuses := range statusCounts {
-			for status, count := range statuses {
+			if c.perTransaction && c.perCompleted {
 				ch <- prometheus.MustNewConstMetric(
-					c.completedTransactionMetric,
-					prometheus.CounterValue,
-					float64(count),
-					skillID, status,
+					c.completedPageCountMetric,
+					prometheus.GaugeValue,
+					float64(tx.PageCount),
+					tx.SkillID, tx.ID,
+				)
+				ch <- prometheus.MustNewConstMetric(
+					c.completedDocumentCountMetric,
+					prometheus.GaugeValue,
+					float64(tx.DocumentCount),
+					tx.SkillID, tx.ID,
 				)
 			}
 		}
-	}
-}
 
-// getToken gets OAuth2 access token
-func (c *vantageCollector) getToken() (string, error) {
-	data := url.Values{}
-	data.Set("grant_type", "client_credentials")
-	data.Set("client_id", c.clientID)
-	data.Set("client_secret", c.clientSecret)
-	data.Set("scope", "global.wildcard openid permissions")
+		c.collectProcessingDurations(ch, filter)
+		c.collectAverageProcessing(ch, averages)
 
-	resp, err := http.Post(
-		c.baseURL+"/auth2/connect/token",
-		"application/x-www-form-urlencoded",
-		strings.NewReader(data.Encode()),
-	)
-	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
+		c.collectCompletedTotals(ch, filter)
 
-	var tokenResp TokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return "", err
+		if c.enableDetailMetrics {
+			c.collectDetailMetrics(ctx, ch, filter, completedTransactions)
+		}
 	}
-	return tokenResp.AccessToken, nil
+
+	// A failed list fetch leaves its slice nil, so the averages then cover
+	// whichever set was fetched
+	c.collectAverages(ch, activeTransactions, completedTransactions)
+	c.collectSkillVersions(ch, skills, activeTransactions, completedTransactions)
 }
 
-// getSkills fetches skills from Vantage API
-func (c *vantageCollector) getSkills() ([]Skill, error) {
-	token, err := c.getToken()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get token: %w", err)
+// countCompleted adds newly completed transactions to the running totals.
+// Counting only unseen transactions keeps the counters monotonic even though
+// each scrape re-fetches an overlapping window of recent completions.
+func (c *vantageCollector) countCompleted(completed []Transaction) {
+	c.totalsMu.Lock()
+	defer c.totalsMu.Unlock()
+
+	for _, tx := range completed {
+		if c.seenCompleted.add(tx.ID) {
+			// The category comes from the raw status, so a status collapsed
+			// into "other" still counts as the failure or success it was
+			category := c.statuses.classify(tx.Status)
+			key := [3]string{tx.SkillID, c.countedStatus(tx.Status), category}
+			c.completedCounts[key]++
+			c.pagesProcessed[tx.SkillID] += tx.PageCount
+			c.documentsProcessed[tx.SkillID] += tx.DocumentCount
+			if duration, ok := processingDuration(tx); ok {
+				c.processingDurations.WithLabelValues(tx.SkillID).Observe(duration.Seconds())
+			}
+			if category == statusFailed {
+				c.recordFailedExemplar(key, tx)
+				c.failureCounts[[2]string{tx.SkillID, c.failureReasons.classify(tx.Error)}]++
+			}
+		}
 	}
+}
 
-	req, err := http.NewRequest("GET", c.baseURL+"/api/publicapi/v1/skills", nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+token)
+// collectCompletedTotals emits the running completion totals for the skills
+// the filter allows
+func (c *vantageCollector) collectCompletedTotals(ch chan<- prometheus.Metric, filter skillFilter) {
+	c.totalsMu.Lock()
+	defer c.totalsMu.Unlock()
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
+	for key, count := range c.completedCounts {
+		if !filter.allows(key[0]) {
+			continue
+		}
+		metric := prometheus.MustNewConstMetric(
+			c.completedTransactionMetric,
+			prometheus.CounterValue,
+			float64(count),
+			key[0], key[1], key[2],
+		)
+		// Exemplars only appear when the scrape negotiates OpenMetrics
+		if exemplar, ok := c.failedExemplars[key]; ok {
+			metric = prometheus.MustNewMetricWithExemplars(metric, exemplar)
+		}
+		ch <- metric
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	for key, count := range c.failureCounts {
+		if !filter.allows(key[0]) {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.failureReasonMetric,
+			prometheus.CounterValue,
+			float64(count),
+			key[0], key[1],
+		)
 	}
 
-	log.Printf("Skills API Response Status: %d", resp.StatusCode)
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	for skillID, pages := range c.pagesProcessed {
+		if !filter.allows(skillID) {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.pagesProcessedMetric,
+			prometheus.CounterValue,
+			float64(pages),
+			skillID,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.documentsProcessedMetric,
+			prometheus.CounterValue,
+			float64(c.documentsProcessed[skillID]),
+			skillID,
+		)
 	}
+}
 
-	if len(body) == 0 {
-		log.Println("Empty response from skills API")
-		return []Skill{}, nil
+// recordFailedExemplar keeps the most recently completed failed transaction
+// of a skill and status as the exemplar of its completed counter. The caller
+// must hold totalsMu.
+func (c *vantageCollector) recordFailedExemplar(key [3]string, tx Transaction) {
+	completedAt, ok := parseTimestamp(tx.ID, "completedUtc", tx.CompletedUtc)
+	if !ok {
+		completedAt = time.Now()
 	}
-
-	var skills []Skill
-	if err := json.Unmarshal(body, &skills); err != nil {
-		return nil, fmt.Errorf("failed to parse skills JSON: %w", err)
+	if previous, ok := c.failedExemplars[key]; ok && previous.Timestamp.After(completedAt) {
+		return
+	}
+	c.failedExemplars[key] = prometheus.Exemplar{
+		Value:     1,
+		Labels:    prometheus.Labels{"transaction_id": tx.ID},
+		Timestamp: completedAt,
 	}
-
-	log.Printf("Found %d skills", len(skills))
-	return skills, nil
 }
 
-// getActiveTransactions fetches active transactions from Vantage API
-func (c *vantageCollector) getActiveTransactions() ([]Transaction, error) {
-	token, err := c.getToken()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get token: %w", err)
+// collectSkillVersions records the newest skill version seen in any
+// transaction and emits it for every known skill. Versions are remembered
+// across scrapes so idle skills keep reporting the version they last ran;
+// skills never seen in a transaction report "unknown".
+func (c *vantageCollector) collectSkillVersions(ch chan<- prometheus.Metric, skills []Skill, transactionSets ...[]Transaction) {
+	c.versionsMu.Lock()
+	defer c.versionsMu.Unlock()
+
+	for _, transactions := range transactionSets {
+		for _, tx := range transactions {
+			if tx.SkillVersion > c.skillVersions[tx.SkillID] {
+				c.skillVersions[tx.SkillID] = tx.SkillVersion
+			}
+		}
 	}
 
-	req, err := http.NewRequest("GET", c.baseURL+"/api/publicapi/v1/transactions/active?Limit=100", nil)
-	if err != nil {
-		return nil, err
+	for _, skill := range skills {
+		version := "unknown"
+		if v, ok := c.skillVersions[skill.ID]; ok {
+			version = strconv.Itoa(v)
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.skillVersionMetric,
+			prometheus.GaugeValue,
+			1,
+			skill.ID, c.skillNameLabel(skill), version,
+		)
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	req = req.WithContext(ctx)
+}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+// collectAverages emits per-skill page and document averages over the
+// combined transaction sets. Skills without transactions get no series.
+func (c *vantageCollector) collectAverages(ch chan<- prometheus.Metric, transactionSets ...[]Transaction) {
+	type totals struct{ transactions, pages, documents int }
+	bySkill := make(map[string]*totals)
+	for _, transactions := range transactionSets {
+		for _, tx := range transactions {
+			t := bySkill[tx.SkillID]
+			if t == nil {
+				t = &totals{}
+				bySkill[tx.SkillID] = t
+			}
+			t.transactions++
+			t.pages += tx.PageCount
+			t.documents += tx.DocumentCount
+		}
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	for skillID, t := range bySkill {
+		ch <- prometheus.MustNewConstMetric(
+			c.avgPagesMetric,
+			prometheus.GaugeValue,
+			float64(t.pages)/float64(t.transactions),
+			skillID,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.avgDocumentsMetric,
+			prometheus.GaugeValue,
+			float64(t.documents)/float64(t.transactions),
+			skillID,
+		)
 	}
+}
 
-	log.Printf("Active Transactions API Response Status: %d", resp.StatusCode)
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+// collectAverageProcessing emits each skill's mean processing time over the
+// completions fetched by this scrape, along with the samples it covers
+func (c *vantageCollector) collectAverageProcessing(ch chan<- prometheus.Metric, averages map[string]*processingAverage) {
+	for skillID, average := range averages {
+		mean, ok := average.mean()
+		if !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.avgProcessingMetric,
+			prometheus.GaugeValue,
+			mean,
+			skillID,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.avgProcessingSamplesMetric,
+			prometheus.GaugeValue,
+			float64(average.samples),
+			skillID,
+		)
 	}
+}
 
-	if len(body) == 0 {
-		log.Println("Empty response from active transactions API")
-		return []Transaction{}, nil
-	}
+// collectActiveTransaction emits the per-transaction series for an active
+// transaction
+func (c *vantageCollector) collectActiveTransaction(ch chan<- prometheus.Metric, tx Transaction) {
+	ch <- prometheus.MustNewConstMetric(
+		c.transactionMetric,
+		prometheus.GaugeValue,
+		1,
+		tx.ID, tx.SkillID,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.transactionPageCountMetric,
+		prometheus.GaugeValue,
+		float64(tx.PageCount),
+		tx.SkillID, tx.ID,
+	)
+	ch <- prometheus.MustNewConstMetric(
+		c.transactionDocumentCountMetric,
+		prometheus.GaugeValue,
+		float64(tx.DocumentCount),
+		tx.SkillID, tx.ID,
+	)
 
-	var response TransactionResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse active transactions JSON: %w", err)
+	if created, ok := parseTimestamp(tx.ID, "createTimeUtc", tx.CreateTimeUtc); ok {
+		ch <- prometheus.MustNewConstMetric(
+			c.transactionCreatedMetric,
+			prometheus.GaugeValue,
+			float64(created.Unix()),
+			tx.SkillID, tx.ID,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			c.activeTransactionAgeMetric,
+			prometheus.GaugeValue,
+			time.Since(created).Seconds(),
+			tx.SkillID, tx.ID,
+		)
 	}
-
-	log.Printf("Found %d active transactions", len(response.Items))
-	return response.Items, nil
 }
 
-// getCompletedTransactions fetches completed transactions with enhanced data
-func (c *vantageCollector) getCompletedTransactions() ([]Transaction, error) {
-	token, err := c.getToken()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get token: %w", err)
+// dropDisabled returns a channel that forwards to ch every metric except the
+// disabled ones, and a function that waits for forwarding to finish once
+// nothing more will be sent
+func (c *vantageCollector) dropDisabled(ch chan<- prometheus.Metric) (chan<- prometheus.Metric, func()) {
+	enabled := make(chan prometheus.Metric)
+	forwarded := make(chan struct{})
+	go func() {
+		for m := range enabled {
+			if !c.disabled[m.Desc()] {
+				ch <- m
+			}
+		}
+		close(forwarded)
+	}()
+	return enabled, func() {
+		close(enabled)
+		<-forwarded
 	}
+}
 
-	req, err := http.NewRequest("GET", c.baseURL+"/api/publicapi/v1/transactions/completed?Limit=100", nil)
-	if err != nil {
-		return nil, err
+// disabledMetrics resolves the comma-separated metric names in list against
+// the collector's Descs, warning about names that match none
+func disabledMetrics(descNames map[*prometheus.Desc]string, list string) map[*prometheus.Desc]bool {
+	names := toSet(splitList(list))
+	disabled := make(map[*prometheus.Desc]bool)
+	for desc, name := range descNames {
+		if names[name] {
+			disabled[desc] = true
+			delete(names, name)
+		}
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-	req = req.WithContext(ctx)
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	for name := range names {
+		log.Printf("Ignoring unknown metric %q in VANTAGE_DISABLED_METRICS", name)
 	}
-	defer resp.Body.Close()
+	return disabled
+}
 
-	body, err := io.ReadAll(resp.Body)
+// observeScrape records the duration and outcome of a fetch made during Collect
+func (c *vantageCollector) observeScrape(endpoint string, start time.Time, err error) {
+	c.self.scrapeDuration.WithLabelValues(c.tenant, endpoint).Set(time.Since(start).Seconds())
 	if err != nil {
-		return nil, err
+		c.self.scrapeErrors.WithLabelValues(c.tenant, endpoint).Inc()
+		return
 	}
+	c.self.lastSuccess.WithLabelValues(c.tenant, endpoint).SetToCurrentTime()
+}
 
-	log.Printf("Completed Transactions API Response Status: %d", resp.StatusCode)
+// inManualReview reports whether an active transaction has been picked up by
+// a manual review operator
+func inManualReview(tx Transaction) bool {
+	return tx.operator() != ""
+}
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+// processingDuration returns the time between a transaction's creation and
+// completion, or false when either timestamp is missing or unparseable or
+// the completion precedes the creation. A zero duration is kept.
+func processingDuration(tx Transaction) (time.Duration, bool) {
+	created, ok := parseTimestamp(tx.ID, "createTimeUtc", tx.CreateTimeUtc)
+	if !ok {
+		return 0, false
 	}
-
-	if len(body) == 0 {
-		log.Println("Empty response from completed transactions API")
-		return []Transaction{}, nil
+	completed, ok := parseTimestamp(tx.ID, "completedUtc", tx.CompletedUtc)
+	if !ok {
+		return 0, false
 	}
-
-	var response TransactionResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse completed transactions JSON: %w", err)
+	duration := completed.Sub(created)
+	if duration < 0 {
+		debugf("Transaction %s completed before it was created", tx.ID)
+		return 0, false
 	}
+	return duration, true
+}
 
-	log.Printf("Found %d completed transactions", len(response.Items))
-	return response.Items, nil
+// processingAverage averages the processing durations of the transactions
+// added to it, skipping those processingDuration rejects
+type processingAverage struct {
+	total   float64
+	samples int
 }
 
-// getTransactionDetail fetches detailed information for a single transaction
-func (c *vantageCollector) getTransactionDetail(transactionID string) (*TransactionDetail, error) {
-	token, err := c.getToken()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get token: %w", err)
+func (a *processingAverage) add(tx Transaction) {
+	if duration, ok := processingDuration(tx); ok {
+		a.total += duration.Seconds()
+		a.samples++
 	}
+}
 
-	req, err := http.NewRequest("GET", c.baseURL+"/api/publicapi/v1/transactions/"+transactionID, nil)
-	if err != nil {
-		return nil, err
+// mean returns the average in seconds, or false without samples
+func (a processingAverage) mean() (float64, bool) {
+	if a.samples == 0 {
+		return 0, false
 	}
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	req = req.WithContext(ctx)
+	return a.total / float64(a.samples), true
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+// parseTimestamp parses an RFC3339 transaction timestamp, logging at debug
+// level and returning false when it is empty or malformed
+func parseTimestamp(transactionID, field, value string) (time.Time, bool) {
+	if value == "" {
+		debugf("Transaction %s has no %s", transactionID, field)
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, value)
 	if err != nil {
-		return nil, err
+		debugf("Transaction %s has malformed %s %q: %v", transactionID, field, value, err)
+		return time.Time{}, false
 	}
-	defer resp.Body.Close()
+	return t, true
+}
 
-	if resp.StatusCode != 200 {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+// fetchDetails fetches the detail of each transaction with up to
+// detailConcurrency requests in flight. The result at each index is nil when
+// that transaction's fetch failed.
+func (c *vantageCollector) fetchDetails(ctx context.Context, transactions []Transaction) []*TransactionDetail {
+	details := make([]*TransactionDetail, len(transactions))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(c.detailConcurrency, len(transactions)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				detail, err := c.getTransactionDetail(ctx, transactions[i].ID)
+				if err != nil {
+					log.Printf("Error getting detail for transaction %s: %v", transactions[i].ID, err)
+					continue
+				}
+				// Each index is written by exactly one worker
+				details[i] = detail
+			}
+		}()
 	}
-
+	for i := range transactions {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return details
+}
+
+// collectProcessingDurations emits the processing duration histograms of
+// the skills the filter allows. dropDisabled can't recognize the vec's Desc,
+// so a disabled histogram is skipped here.
+func (c *vantageCollector) collectProcessingDurations(ch chan<- prometheus.Metric, filter skillFilter) {
+	if c.disabled[c.processingDurationMetric] {
+		return
+	}
+	histograms := make(chan prometheus.Metric)
+	go func() {
+		c.processingDurations.Collect(histograms)
+		close(histograms)
+	}()
+	for m := range histograms {
+		if skillID, ok := metricSkillID(m); ok && filter.allows(skillID) {
+			ch <- m
+		}
+	}
+}
+
+// collectDetailMetrics fetches per-transaction detail for completed
+// transactions and emits the metrics derived from it. Per-skill business rule
+// error totals take each transaction's errors into account once; when
+// per-transaction series are disabled, transactions already counted are not
+// fetched again. Details held in the detail cache are reused rather than
+// fetched and don't count against detailMax, the most details fetched per
+// scrape, newest transactions first.
+func (c *vantageCollector) collectDetailMetrics(ctx context.Context, ch chan<- prometheus.Metric, filter skillFilter, transactions []Transaction) {
+	var pending, fetch []Transaction
+	var details []*TransactionDetail
+	skipped := 0
+	for _, tx := range transactions {
+		if !c.perTransaction && c.seenDetails.has(tx.ID) {
+			skipped++
+			continue
+		}
+		if detail, ok := c.cachedDetail(tx.ID); ok {
+			skipped++
+			pending = append(pending, tx)
+			details = append(details, detail)
+			continue
+		}
+		fetch = append(fetch, tx)
+	}
+	capped := 0
+	if len(fetch) > c.detailMax {
+		capped = len(fetch) - c.detailMax
+		fetch = fetch[:c.detailMax]
+	}
+
+	// Cached details come first, followed by the fetched ones in order
+	pending = append(pending, fetch...)
+	details = append(details, c.fetchDetails(ctx, fetch)...)
+	failed := 0
+	for i, tx := range pending {
+		detail := details[i]
+		if detail == nil {
+			failed++
+			continue
+		}
+
+		if c.seenDetails.add(tx.ID) {
+			c.totalsMu.Lock()
+			for _, doc := range detail.Documents {
+				for _, ruleErr := range doc.BusinessRulesErrors {
+					c.ruleErrorCounts[[2]string{tx.SkillID, ruleErr.Type}]++
+				}
+				for _, file := range doc.ResultFiles {
+					c.fileTypeCounts[[2]string{tx.SkillID, file.Type}]++
+				}
+			}
+			for _, file := range detail.SourceFiles {
+				c.sourceTypeCounts[[2]string{tx.SkillID, sourceFileType(file.Name)}]++
+			}
+			c.totalsMu.Unlock()
+		}
+		if !c.perTransaction {
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(
+			c.transactionFileCountMetric,
+			prometheus.GaugeValue,
+			float64(len(detail.SourceFiles)),
+			tx.SkillID, tx.ID,
+		)
+
+		errorCounts := make(map[string]int)
+		fileTypeCounts := make(map[string]int)
+		for _, doc := range detail.Documents {
+			for _, ruleErr := range doc.BusinessRulesErrors {
+				errorCounts[ruleErr.Type]++
+			}
+			for _, file := range doc.ResultFiles {
+				fileTypeCounts[file.Type]++
+			}
+		}
+
+		for errorType, count := range errorCounts {
+			ch <- prometheus.MustNewConstMetric(
+				c.businessRulesErrorsMetric,
+				prometheus.CounterValue,
+				float64(count),
+				tx.SkillID, tx.ID, errorType,
+			)
+		}
+
+		for fileType, count := range fileTypeCounts {
+			ch <- prometheus.MustNewConstMetric(
+				c.resultFileTypesMetric,
+				prometheus.CounterValue,
+				float64(count),
+				tx.SkillID, tx.ID, fileType,
+			)
+		}
+	}
+
+	for outcome, count := range map[string]int{
+		"fetched": len(fetch) - failed,
+		"failed":  failed,
+		"skipped": skipped,
+		"capped":  capped,
+	} {
+		ch <- prometheus.MustNewConstMetric(
+			c.detailFetchesMetric,
+			prometheus.GaugeValue,
+			float64(count),
+			outcome,
+		)
+	}
+
+	c.totalsMu.Lock()
+	defer c.totalsMu.Unlock()
+	for key, count := range c.ruleErrorCounts {
+		if !filter.allows(key[0]) {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.ruleErrorsBySkillMetric,
+			prometheus.CounterValue,
+			float64(count),
+			key[0], key[1],
+		)
+	}
+	for key, count := range c.fileTypeCounts {
+		if !filter.allows(key[0]) {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.fileTypesBySkillMetric,
+			prometheus.CounterValue,
+			float64(count),
+			key[0], key[1],
+		)
+	}
+	for key, count := range c.sourceTypeCounts {
+		if !filter.allows(key[0]) {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(
+			c.sourceFileTypesMetric,
+			prometheus.CounterValue,
+			float64(count),
+			key[0], key[1],
+		)
+	}
+}
+
+// sourceFileType returns a source file's type as its lowercased extension,
+// so scan.PDF and scan.pdf both count as pdf
+func sourceFileType(name string) string {
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(name), "."))
+	if ext == "" {
+		return "none"
+	}
+	return ext
+}
+
+// authHeader returns the header that authenticates a Vantage API request in
+// the configured auth mode. An API key in the Authorization header is sent
+// with the ApiKey scheme, in any other header as is.
+func (c *vantageCollector) authHeader(ctx context.Context) (string, string, error) {
+	if c.authMode == authModeAPIKey {
+		if strings.EqualFold(c.apiKeyHeader, "Authorization") {
+			return "Authorization", "ApiKey " + c.apiKey, nil
+		}
+		return c.apiKeyHeader, c.apiKey, nil
+	}
+
+	token, err := c.getToken(ctx)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get token: %w", err)
+	}
+	return "Authorization", "Bearer " + token, nil
+}
+
+// getToken returns a cached OAuth2 access token, fetching a new one when the
+// cached token is missing or close to expiry
+func (c *vantageCollector) getToken(ctx context.Context) (string, error) {
+	c.tokenMu.Lock()
+	if c.token != "" && time.Until(c.tokenExpiry) > tokenRefreshMargin {
+		token := c.token
+		c.tokenMu.Unlock()
+		return token, nil
+	}
+	c.tokenMu.Unlock()
+
+	// Concurrent callers share one in-flight fetch. It is detached from the
+	// first caller's cancellation so one abandoned scrape can't fail the
+	// others; fetchToken still bounds it with httpTimeout.
+	result := c.tokenFlight.DoChan("token", func() (interface{}, error) {
+		return c.refreshToken(context.WithoutCancel(ctx))
+	})
+	select {
+	case res := <-result:
+		if res.Err != nil {
+			return "", res.Err
+		}
+		return res.Val.(string), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// refreshToken fetches a new token and caches it until shortly before it
+// expires
+func (c *vantageCollector) refreshToken(ctx context.Context) (string, error) {
+	tokenResp, err := c.fetchToken(ctx)
+	if err != nil {
+		return "", c.redactError(err)
+	}
+
+	lifetime := defaultTokenLifetime
+	if tokenResp.ExpiresIn > 0 {
+		lifetime = time.Duration(tokenResp.ExpiresIn) * time.Second
+	}
+
+	c.tokenMu.Lock()
+	c.token = tokenResp.AccessToken
+	c.tokenExpiry = time.Now().Add(lifetime)
+	c.tokenMu.Unlock()
+
+	c.healthMu.Lock()
+	c.lastTokenSuccess = time.Now()
+	c.healthMu.Unlock()
+
+	return tokenResp.AccessToken, nil
+}
+
+// fetchToken requests a new OAuth2 access token from the Vantage identity endpoint
+func (c *vantageCollector) fetchToken(ctx context.Context) (*TokenResponse, error) {
+	data := url.Values{}
+	data.Set("grant_type", "client_credentials")
+	data.Set("client_id", c.clientID)
+	data.Set("client_secret", c.clientSecret)
+	data.Set("scope", c.oauthScope)
+	debugf("Requesting token for tenant %s with scope %q", c.tenant, c.oauthScope)
+
+	ctx, cancel := context.WithTimeout(ctx, c.httpTimeout)
+	defer cancel()
+
+	tokenURL, err := c.apiURL(nil, c.tokenPath...)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.do(req, "token")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("token endpoint: %w", c.statusError(resp))
+	}
+
+	var tokenResp TokenResponse
+	if err := c.decodeBody(resp.Body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("failed to parse token JSON: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned status %d with no access token", resp.StatusCode)
+	}
+	return &tokenResp, nil
+}
+
+// maxRateLimitRetries bounds how often one request is retried after a 429
+const maxRateLimitRetries = 3
+
+// apiURL builds a Vantage URL from the base URL, path segments and optional
+// query parameters. Each segment is escaped, so IDs containing slashes or
+// spaces stay a single segment, and a trailing slash on the base URL is
+// tolerated.
+func (c *vantageCollector) apiURL(query url.Values, segments ...string) (string, error) {
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid VANTAGE_BASE_URL %q: %w", c.baseURL, err)
+	}
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = url.PathEscape(segment)
+	}
+	u := base.JoinPath(escaped...)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// do sends an outbound Vantage request unless the endpoint's circuit is
+// open, in which case it fails straight away with errCircuitOpen
+func (c *vantageCollector) do(req *http.Request, endpoint string) (*http.Response, error) {
+	if err := c.breaker.allow(endpoint); err != nil {
+		return nil, err
+	}
+	resp, err := c.doRetrying(req, endpoint)
+	c.breaker.record(endpoint, resp, err)
+	return resp, err
+}
+
+// doRetrying sends a request, retrying a 429 response after the delay in its
+// Retry-After header, capped at maxRetryAfter, as long as the retry still
+// fits in the request's deadline; otherwise the 429 is returned to the
+// caller like any other non-200 status.
+func (c *vantageCollector) doRetrying(req *http.Request, endpoint string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.doOnce(req, endpoint)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+		c.self.apiRateLimited.WithLabelValues(c.tenant).Inc()
+
+		wait := min(retryAfter(resp.Header.Get("Retry-After")), c.maxRetryAfter)
+		ctx := req.Context()
+		if deadline, ok := ctx.Deadline(); attempt >= maxRateLimitRetries || (ok && time.Until(deadline) < wait) {
+			log.Printf("Vantage API rate limited %s %s, giving up", req.Method, req.URL.Path)
+			return resp, nil
+		}
+		resp.Body.Close()
+		log.Printf("Vantage API rate limited %s %s, retrying in %s", req.Method, req.URL.Path, wait)
+
+		if req, err = rewind(req); err != nil {
+			return nil, err
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP
+// date, defaulting to one second when it is missing or malformed
+func retryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return time.Second
+}
+
+// rewind returns a copy of req whose body can be sent again
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.GetBody == nil {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	retry := req.Clone(req.Context())
+	retry.Body = body
+	return retry, nil
+}
+
+// doOnce sends a request once the tenant's limiter admits it and counts the
+// response status. The limiter slot is held until the response body is
+// closed.
+func (c *vantageCollector) doOnce(req *http.Request, endpoint string) (*http.Response, error) {
+	release, err := c.limiter.acquire(req.Context())
+	if err != nil {
+		if errors.Is(err, errThrottled) {
+			c.self.apiThrottled.WithLabelValues(c.tenant).Inc()
+		}
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.correlationHeader != "" {
+		req.Header.Set(c.correlationHeader, newRequestID())
+	}
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	c.self.apiDuration.WithLabelValues(c.tenant, endpoint).Observe(time.Since(start).Seconds())
+	if err != nil {
+		c.self.apiRequests.WithLabelValues(c.tenant, endpoint, "error").Inc()
+		release()
+		if c.correlationHeader != "" {
+			err = fmt.Errorf("%w (correlation ID %s)", err, req.Header.Get(c.correlationHeader))
+		}
+		return nil, err
+	}
+	c.self.apiRequests.WithLabelValues(c.tenant, endpoint, strconv.Itoa(resp.StatusCode)).Inc()
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// apiStatusError is returned when a Vantage API call answers with a non-200
+// status, so callers can react to specific statuses
+type apiStatusError struct {
+	statusCode int
+	body       string
+	ids        string // from requestIDs
+}
+
+func (e *apiStatusError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s%s", e.statusCode, e.body, e.ids)
+}
+
+// checkSchema flags expected fields that are empty in every one of n decoded
+// items, which usually means the API renamed or dropped them rather than
+// that the data is really missing. fields returns item i's expected fields
+// by their JSON name.
+func (c *vantageCollector) checkSchema(endpoint string, n int, fields func(i int) map[string]string) {
+	if n == 0 {
+		return
+	}
+	populated := make(map[string]bool)
+	for i := 0; i < n; i++ {
+		for name, value := range fields(i) {
+			if value != "" {
+				populated[name] = true
+			}
+		}
+	}
+	for name := range fields(0) {
+		if !populated[name] {
+			c.self.schemaWarnings.WithLabelValues(c.tenant, endpoint, name).Inc()
+			debugf("Field %s is empty in all %d items from the %s endpoint; the API schema may have changed", name, n, endpoint)
+		}
+	}
+}
+
+// maxErrorBodySize bounds how much of a non-200 response body is kept for
+// the error message
+const maxErrorBodySize = 4 << 10
+
+// readErrorBody returns the start of a non-200 response body
+func readErrorBody(body io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(body, maxErrorBodySize))
+	return string(data)
+}
+
+// errResponseTooLarge is returned for a response body over maxResponseSize
+var errResponseTooLarge = errors.New("response too large")
+
+// decodeBody decodes a JSON response body as it streams in, failing with
+// errResponseTooLarge once it exceeds maxResponseSize. An empty body yields
+// io.EOF.
+func (c *vantageCollector) decodeBody(body io.Reader, v interface{}) error {
+	limited := &io.LimitedReader{R: body, N: c.maxResponseSize + 1}
+	err := json.NewDecoder(limited).Decode(v)
+	if limited.N <= 0 {
+		return fmt.Errorf("%w: body exceeds %d bytes, raise VANTAGE_MAX_RESPONSE_BYTES", errResponseTooLarge, c.maxResponseSize)
+	}
+	return err
+}
+
+// redactedError hides the client secret in an error message while keeping
+// the original error available to errors.Is and errors.As
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// redactError scrubs the client secret, raw or form-encoded, from err so it
+// can never end up in logs or HTTP responses
+func (c *vantageCollector) redactError(err error) error {
+	if err == nil || (c.clientSecret == "" && c.apiKey == "") {
+		return err
+	}
+	return &redactedError{msg: c.redact(err.Error()), err: err}
+}
+
+func (c *vantageCollector) redact(s string) string {
+	for _, secret := range []string{c.clientSecret, c.apiKey} {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, "****")
+			s = strings.ReplaceAll(s, url.QueryEscape(secret), "****")
+		}
+	}
+	return s
+}
+
+// getSkills fetches skills from Vantage API
+func (c *vantageCollector) getSkills(ctx context.Context) ([]Skill, error) {
+	authName, authValue, err := c.authHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.httpTimeout)
+	defer cancel()
+
+	skillsURL, err := c.apiURL(nil, "api", "publicapi", "v1", "skills")
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", skillsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(authName, authValue)
+
+	resp, err := c.do(req, "skills")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	log.Printf("Skills API Response Status: %d%s", resp.StatusCode, c.requestIDs(resp))
+
+	if resp.StatusCode != 200 {
+		return nil, c.statusError(resp)
+	}
+
+	var skills []Skill
+	if err := c.decodeBody(resp.Body, &skills); err == io.EOF {
+		log.Println("Empty response from skills API")
+		return []Skill{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to parse skills JSON: %w", err)
+	}
+	c.checkSchema("skills", len(skills), func(i int) map[string]string {
+		return map[string]string{"id": skills[i].ID, "name": skills[i].Name}
+	})
+
+	c.healthMu.Lock()
+	c.lastScrapeSuccess = time.Now()
+	c.healthMu.Unlock()
+
+	log.Printf("Found %d skills", len(skills))
+	return skills, nil
+}
+
+// The processing duration histogram is a vec whose name and help must match
+// its Desc
+const (
+	processingDurationName = "vantage_transaction_processing_duration_seconds"
+	processingDurationHelp = "Time from creation to completion of completed transactions"
+)
+
+// nativeHistogramFactor reads VANTAGE_NATIVE_HISTOGRAM_FACTOR, the growth
+// factor between native histogram buckets. Native histograms need a factor
+// above 1; anything else leaves them off.
+func nativeHistogramFactor() float64 {
+	factor := getEnvFloat("VANTAGE_NATIVE_HISTOGRAM_FACTOR", 0)
+	if factor <= 1 {
+		return 0
+	}
+	return factor
+}
+
+// defaultDurationBuckets covers processing times from seconds up to a day
+var defaultDurationBuckets = []float64{10, 30, 60, 120, 300, 600, 1800, 3600, 7200, 21600, 86400}
+
+// The number of transactions requested per page defaults to
+// defaultPageLimit and is clamped to the range the API accepts
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// clampPageLimit keeps a configured page limit within 1..maxPageLimit
+func clampPageLimit(limit int) int {
+	clamped := min(max(limit, 1), maxPageLimit)
+	if clamped != limit {
+		log.Printf("VANTAGE_PAGE_LIMIT %d is outside 1-%d, using %d", limit, maxPageLimit, clamped)
+	}
+	return clamped
+}
+
+// getActiveTransactions fetches active transactions from Vantage API
+func (c *vantageCollector) getActiveTransactions(ctx context.Context) ([]Transaction, error) {
+	return c.getTransactions(ctx, "active", "active transactions", nil)
+}
+
+// getCompletedTransactions fetches completed transactions with enhanced data.
+// With a lookback configured only transactions created within it are
+// requested.
+func (c *vantageCollector) getCompletedTransactions(ctx context.Context) ([]Transaction, error) {
+	return c.getTransactions(ctx, "completed", "completed transactions", c.completedFilter())
+}
+
+// cursorOverlap is how far before the cursor the next cursor fetch starts, so
+// completions recorded late by the API are still caught. The seen set keeps
+// the overlap from being counted twice.
+const cursorOverlap = time.Minute
+
+// getScrapeCompletedTransactions fetches the completed transactions a scrape
+// works on. With VANTAGE_COMPLETED_CURSOR only those completed since the
+// newest completion of the last complete fetch are requested.
+func (c *vantageCollector) getScrapeCompletedTransactions(ctx context.Context) ([]Transaction, error) {
+	if !c.completedCursor {
+		return c.getCompletedTransactions(ctx)
+	}
+
+	filter := c.completedFilter()
+	c.cursorMu.Lock()
+	cursor := c.cursor
+	c.cursorMu.Unlock()
+	if !cursor.IsZero() {
+		if filter == nil {
+			filter = url.Values{}
+		}
+		filter.Set("completedAfter", cursor.Add(-cursorOverlap).Format(time.RFC3339))
+	}
+
+	transactions, complete, err := c.fetchTransactions(ctx, "completed", "completed transactions", filter)
+	if err != nil {
+		return nil, err
+	}
+	// Advancing past a list cut off by maxPages would skip the completions
+	// that weren't fetched, so the cursor waits for a complete fetch
+	if complete {
+		c.advanceCursor(transactions)
+	}
+	return transactions, nil
+}
+
+// advanceCursor moves the cursor to the newest completion among transactions
+func (c *vantageCollector) advanceCursor(transactions []Transaction) {
+	c.cursorMu.Lock()
+	defer c.cursorMu.Unlock()
+	for _, tx := range transactions {
+		if completed, ok := parseTimestamp(tx.ID, "completedUtc", tx.CompletedUtc); ok && completed.After(c.cursor) {
+			c.cursor = completed.UTC()
+		}
+	}
+}
+
+// completedFilter returns the query parameters restricting the completed
+// list to the lookback window, or nil without one
+func (c *vantageCollector) completedFilter() url.Values {
+	if c.lookback <= 0 {
+		return nil
+	}
+	now := time.Now().UTC()
+	filter := url.Values{}
+	filter.Set("createdAfter", now.Add(-c.lookback).Format(time.RFC3339))
+	filter.Set("createdBefore", now.Format(time.RFC3339))
+	return filter
+}
+
+// getTransactions walks the pages of a transaction list endpoint until
+// TotalItemCount is reached or maxPages pages have been fetched. filter holds
+// extra query parameters sent with every page.
+func (c *vantageCollector) getTransactions(ctx context.Context, list, kind string, filter url.Values) ([]Transaction, error) {
+	transactions, _, err := c.fetchTransactions(ctx, list, kind, filter)
+	return transactions, err
+}
+
+// fetchTransactions is getTransactions that also reports whether the whole
+// list was fetched rather than cut off by maxPages
+func (c *vantageCollector) fetchTransactions(ctx context.Context, list, kind string, filter url.Values) ([]Transaction, bool, error) {
+	var transactions []Transaction
+	seen := make(map[string]bool)
+	excluded := c.typeExcludedSkills(ctx)
+
+	for page := 0; page < c.maxPages; page++ {
+		response, err := c.getTransactionPage(ctx, list, kind, filter, page*c.pageLimit)
+		if err != nil {
+			return nil, false, err
+		}
+
+		// Items can shift between pages while we walk them, so drop repeats
+		// rather than emitting duplicate series
+		for _, tx := range response.Items {
+			if seen[tx.ID] || excluded[tx.SkillID] {
+				continue
+			}
+			seen[tx.ID] = true
+			transactions = append(transactions, tx)
+		}
+		// A cursor fetch lists only the completions since the cursor, whose
+		// total says nothing about the lookback window
+		if !filter.Has("completedAfter") {
+			c.setListTotal(list, response.TotalItemCount)
+		}
+		if len(response.Items) < c.pageLimit || len(transactions) >= response.TotalItemCount {
+			log.Printf("Found %d %s", len(transactions), kind)
+			c.recent.put(transactions)
+			return transactions, true, nil
+		}
+	}
+
+	log.Printf("Stopped after %d pages of %s (%d fetched), raise VANTAGE_MAX_PAGES to fetch more", c.maxPages, kind, len(transactions))
+	c.recent.put(transactions)
+	return transactions, false, nil
+}
+
+func (c *vantageCollector) setListTotal(list string, total int) {
+	c.listTotalsMu.Lock()
+	defer c.listTotalsMu.Unlock()
+	c.listTotals[list] = total
+}
+
+// collectListTotal emits the TotalItemCount last reported by a list
+func (c *vantageCollector) collectListTotal(ch chan<- prometheus.Metric, desc *prometheus.Desc, list string) {
+	c.listTotalsMu.Lock()
+	total, ok := c.listTotals[list]
+	c.listTotalsMu.Unlock()
+	if ok {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(total))
+	}
+}
+
+// getTransactionPage fetches a single page of a transaction list endpoint
+func (c *vantageCollector) getTransactionPage(ctx context.Context, list, kind string, filter url.Values, offset int) (*TransactionResponse, error) {
+	authName, authValue, err := c.authHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	for key, values := range filter {
+		query[key] = values
+	}
+	query.Set("Offset", strconv.Itoa(offset))
+	query.Set("Limit", strconv.Itoa(c.pageLimit))
+	pageURL, err := c.apiURL(query, "api", "publicapi", "v1", "transactions", list)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.httpTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(authName, authValue)
+
+	resp, err := c.do(req, list)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	log.Printf("%s API Response Status: %d (offset %d)%s", kind, resp.StatusCode, offset, c.requestIDs(resp))
+
+	if resp.StatusCode != 200 {
+		return nil, c.statusError(resp)
+	}
+
+	var response TransactionResponse
+	if err := c.decodeBody(resp.Body, &response); err == io.EOF {
+		log.Printf("Empty response from %s API", kind)
+		return &TransactionResponse{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to parse %s JSON: %w", kind, err)
+	}
+	for i := range response.Items {
+		response.Items[i].normalize()
+	}
+	c.checkSchema(list, len(response.Items), func(i int) map[string]string {
+		tx := response.Items[i]
+		return map[string]string{"transactionId": tx.ID, "skillId": tx.SkillID, "status": tx.Status, "createTimeUtc": tx.CreateTimeUtc}
+	})
+
+	return &response, nil
+}
+
+// cachedDetail returns a transaction's detail from the detail cache,
+// counting the hit
+func (c *vantageCollector) cachedDetail(transactionID string) (*TransactionDetail, bool) {
+	detail, ok := c.details.get(transactionID)
+	if ok {
+		c.self.detailCache.WithLabelValues(c.tenant, "hit").Inc()
+	}
+	return detail, ok
+}
+
+// getTransactionDetail fetches detailed information for a single transaction.
+// Details of finished transactions are served from the detail cache.
+func (c *vantageCollector) getTransactionDetail(ctx context.Context, transactionID string) (*TransactionDetail, error) {
+	if detail, ok := c.cachedDetail(transactionID); ok {
+		return detail, nil
+	}
+	c.self.detailCache.WithLabelValues(c.tenant, "miss").Inc()
+
+	authName, authValue, err := c.authHeader(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.detailTimeout)
+	defer cancel()
+
+	detailURL, err := c.apiURL(nil, "api", "publicapi", "v1", "transactions", transactionID)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", detailURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(authName, authValue)
+
+	resp, err := c.do(req, "detail")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, c.statusError(resp)
+	}
+
 	var detail TransactionDetail
-	if err := json.NewDecoder(resp.Body).Decode(&detail); err != nil {
+	if err := c.decodeBody(resp.Body, &detail); err != nil {
 		return nil, fmt.Errorf("failed to parse transaction detail JSON: %w", err)
 	}
 
-	return &detail, nil
+	// Only a finished transaction's detail is final
+	if c.finished(transactionID, detail.Status) {
+		if detail.ID == "" {
+			detail.ID = transactionID
+		}
+		c.details.put(&detail)
+	}
+
+	return &detail, nil
+}
+
+// finished reports whether a transaction is done, judging by its status or
+// by its having been listed among the completed transactions
+func (c *vantageCollector) finished(transactionID, status string) bool {
+	switch c.statuses.classify(status) {
+	case statusSuccess, statusFailed:
+		return true
+	}
+	tx, ok := c.recent.get(transactionID)
+	return ok && tx.CompletedUtc != ""
+}
+
+// handleTransactionDetails handles the multi-skill transaction details endpoint
+func (c *vantageCollector) handleTransactionDetails(w http.ResponseWriter, r *http.Request) {
+	response := TransactionDetailsResponse{
+		SchemaVersion: transactionDetailsSchemaVersion,
+		Data:          []TransactionMetrics{},
+		Errors:        []ResponseError{},
+	}
+	fail := func(status int, source, message string) {
+		response.Errors = append(response.Errors, ResponseError{Source: source, Message: message})
+		writeJSON(w, status, response)
+	}
+
+	skillIds, err := parseSkillIDs(r.URL.Query().Get("skills"))
+	if err != nil {
+		fail(http.StatusBadRequest, "request", err.Error())
+		return
+	}
+	if len(skillIds) > c.maxDetailSkills {
+		fail(http.StatusBadRequest, "request", fmt.Sprintf("at most %d skills may be requested at once (VANTAGE_MAX_DETAIL_SKILLS)", c.maxDetailSkills))
+		return
+	}
+
+	// Optional offset and limit bound how many of each skill's transactions,
+	// active first and then completed, are aggregated
+	query := r.URL.Query()
+	if query.Has("offset") || query.Has("limit") {
+		response.Pagination = &Pagination{}
+		for name, value := range map[string]*int{"offset": &response.Pagination.Offset, "limit": &response.Pagination.Limit} {
+			if !query.Has(name) {
+				continue
+			}
+			n, err := strconv.Atoi(query.Get(name))
+			if err != nil || n < 0 {
+				fail(http.StatusBadRequest, "request", fmt.Sprintf("%s must be a non-negative integer", name))
+				return
+			}
+			*value = n
+		}
+	}
+
+	status, body, cacheState := c.cachedTransactionDetails(r.Context(), skillIds, response.Pagination)
+	w.Header().Set("X-Cache", cacheState)
+	if c.detailsCacheTTL > 0 && status == http.StatusOK && len(body.Errors) == 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(c.detailsCacheTTL.Seconds())))
+	} else {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	writeJSON(w, status, body)
+}
+
+// cachedTransactionDetails returns the /transaction-details response for a
+// skill set and window, reusing a cached one for detailsCacheTTL. Once an
+// entry is older than the TTL it is still served for another TTL while a
+// background refresh replaces it, so dashboards rarely wait on the API.
+// Responses with errors are never cached.
+func (c *vantageCollector) cachedTransactionDetails(ctx context.Context, skillIDs []string, pagination *Pagination) (int, TransactionDetailsResponse, string) {
+	if c.detailsCacheTTL <= 0 {
+		status, response := c.buildTransactionDetails(ctx, skillIDs, pagination)
+		return status, response, "MISS"
+	}
+
+	key := strings.Join(skillIDs, ",")
+	if pagination != nil {
+		key += fmt.Sprintf("|%d|%d", pagination.Offset, pagination.Limit)
+	}
+
+	c.detailsMu.Lock()
+	entry, ok := c.detailsCache[key]
+	if ok {
+		age := time.Since(entry.fetched)
+		if age < c.detailsCacheTTL {
+			c.detailsMu.Unlock()
+			return http.StatusOK, entry.response, "HIT"
+		}
+		if age < 2*c.detailsCacheTTL {
+			if !entry.refreshing {
+				entry.refreshing = true
+				go c.refreshTransactionDetails(key, skillIDs, pagination)
+			}
+			c.detailsMu.Unlock()
+			return http.StatusOK, entry.response, "HIT"
+		}
+	}
+	c.detailsMu.Unlock()
+
+	status, response := c.buildTransactionDetails(ctx, skillIDs, pagination)
+	c.storeTransactionDetails(key, status, response)
+	return status, response, "MISS"
 }
 
-// handleTransactionDetails handles the multi-skill transaction details endpoint
-func (c *vantageCollector) handleTransactionDetails(w http.ResponseWriter, r *http.Request) {
-	// Parse skills parameter
-	skillsParam := r.URL.Query().Get("skills")
-	if skillsParam == "" {
-		http.Error(w, "skills parameter required (e.g., ?skills=skill1,skill2,skill3)", http.StatusBadRequest)
-		return
+// refreshTransactionDetails rebuilds a cached response off the request path
+func (c *vantageCollector) refreshTransactionDetails(key string, skillIDs []string, pagination *Pagination) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.scrapeTimeout)
+	defer cancel()
+
+	status, response := c.buildTransactionDetails(ctx, skillIDs, pagination)
+	if !c.storeTransactionDetails(key, status, response) {
+		// Keep serving the previous response until it ages out
+		c.detailsMu.Lock()
+		if entry, ok := c.detailsCache[key]; ok {
+			entry.refreshing = false
+		}
+		c.detailsMu.Unlock()
+	}
+}
+
+// storeTransactionDetails caches a complete, successful response and prunes
+// entries too old to be served. It reports whether the response was cached.
+func (c *vantageCollector) storeTransactionDetails(key string, status int, response TransactionDetailsResponse) bool {
+	if status != http.StatusOK || len(response.Errors) > 0 {
+		return false
 	}
 
-	// Parse comma-separated skill IDs (handle Grafana format with braces)
-	skillsParam = strings.Trim(skillsParam, "{}")
-	skillIds := strings.Split(skillsParam, ",")
+	c.detailsMu.Lock()
+	defer c.detailsMu.Unlock()
 
-	// Clean up skill IDs
-	for i := range skillIds {
-		skillIds[i] = strings.TrimSpace(skillIds[i])
+	now := time.Now()
+	for k, entry := range c.detailsCache {
+		if now.Sub(entry.fetched) >= 2*c.detailsCacheTTL {
+			delete(c.detailsCache, k)
+		}
 	}
+	c.detailsCache[key] = &cachedDetails{response: response, fetched: now}
+	return true
+}
 
-	if len(skillIds) == 0 || (len(skillIds) == 1 && skillIds[0] == "") {
-		http.Error(w, "no valid skill IDs provided", http.StatusBadRequest)
-		return
+// buildTransactionDetails fetches skills and transactions and aggregates the
+// requested skills
+func (c *vantageCollector) buildTransactionDetails(ctx context.Context, skillIds []string, pagination *Pagination) (int, TransactionDetailsResponse) {
+	response := TransactionDetailsResponse{
+		SchemaVersion: transactionDetailsSchemaVersion,
+		Pagination:    pagination,
+		Data:          []TransactionMetrics{},
+		Errors:        []ResponseError{},
 	}
 
 	log.Printf("Processing transaction details for %d skills: %v", len(skillIds), skillIds)
 
-	// Get fresh data using your existing methods
-	skills, err := c.getSkills()
+	// Get fresh data using your existing methods. A failed fetch is reported
+	// in the errors array and the remaining data is still aggregated, so
+	// dashboards degrade gracefully when one upstream call fails.
+	skills, err := c.cachedGetSkills(ctx)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to get skills: %v", err), http.StatusInternalServerError)
-		return
+		response.Errors = append(response.Errors, ResponseError{Source: "skills", Message: fmt.Sprintf("failed to get skills: %v", err)})
 	}
 
-	activeTransactions, err := c.getActiveTransactions()
-	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to get active transactions: %v", err), http.StatusInternalServerError)
-		return
+	activeTransactions, activeErr := c.getActiveTransactions(ctx)
+	if activeErr != nil {
+		response.Errors = append(response.Errors, ResponseError{Source: "active", Message: fmt.Sprintf("failed to get active transactions: %v", activeErr)})
 	}
 
-	completedTransactions, err := c.getCompletedTransactions()
-	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to get completed transactions: %v", err), http.StatusInternalServerError)
-		return
+	completedTransactions, completedErr := c.getCompletedTransactions(ctx)
+	if completedErr != nil {
+		response.Errors = append(response.Errors, ResponseError{Source: "completed", Message: fmt.Sprintf("failed to get completed transactions: %v", completedErr)})
+	}
+
+	if activeErr != nil && completedErr != nil {
+		return http.StatusBadGateway, response
 	}
 
 	// Create skill name lookup
@@ -544,115 +2460,316 @@ func (c *vantageCollector) handleTransactionDetails(w http.ResponseWriter, r *ht
 		skillNames[skill.ID] = skill.Name
 	}
 
-	// Process each requested skill
-	var results []TransactionMetrics
+	// Process the requested skills with up to skillConcurrency at a time. A
+	// skill that can't be built is reported in the errors array and left out
+	// of the data, which otherwise keeps the requested order.
+	results := make([]*TransactionMetrics, len(skillIds))
+	skillErrs := make([]error, len(skillIds))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(c.skillConcurrency, len(skillIds)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				skillId := skillIds[i]
+				skillName := skillNames[skillId]
+				if skillName == "" {
+					skillName = skillId // fallback
+				}
+				// Each index is written by exactly one worker
+				results[i], skillErrs[i] = c.buildSkillDetails(ctx, skillId, skillName, activeTransactions, completedTransactions, pagination)
+			}
+		}()
+	}
+	for i := range skillIds {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, metrics := range results {
+		if skillErrs[i] != nil {
+			response.Errors = append(response.Errors, ResponseError{Source: "skill:" + skillIds[i], Message: skillErrs[i].Error()})
+			continue
+		}
+		response.Data = append(response.Data, *metrics)
+	}
+	log.Printf("Built metrics for %d skills with %d errors", len(response.Data), len(response.Errors))
+	return http.StatusOK, response
+}
+
+// buildSkillDetails aggregates one skill's transactions for
+// /transaction-details, failing once the request's context is done
+func (c *vantageCollector) buildSkillDetails(ctx context.Context, skillID, skillName string, activeTransactions, completedTransactions []Transaction, pagination *Pagination) (*TransactionMetrics, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("failed to build metrics for skill %s: %w", skillID, err)
+	}
+
+	active, completed := forSkill(activeTransactions, skillID), forSkill(completedTransactions, skillID)
+	if p := pagination; p != nil {
+		active, completed = paginate(active, completed, p.Offset, p.Limit)
+	}
+	result := c.skillTransactionMetrics(skillID, skillName, active, completed)
+	log.Printf("Processed skill %s (%s): %d total transactions", skillID, skillName, result.TotalTransactions)
+	return &result, nil
+}
+
+// forSkill returns the transactions belonging to one skill
+func forSkill(transactions []Transaction, skillID string) []Transaction {
+	var kept []Transaction
+	for _, tx := range transactions {
+		if tx.SkillID == skillID {
+			kept = append(kept, tx)
+		}
+	}
+	return kept
+}
+
+// paginate applies an offset and limit to the active transactions followed
+// by the completed ones, returning what remains of each. A zero limit keeps
+// everything after the offset.
+func paginate(active, completed []Transaction, offset, limit int) ([]Transaction, []Transaction) {
+	total := len(active) + len(completed)
+	start := min(offset, total)
+	end := total
+	if limit > 0 {
+		end = min(start+limit, total)
+	}
+
+	split := len(active)
+	return active[min(start, split):min(end, split)], completed[max(start-split, 0):max(end-split, 0)]
+}
+
+// skillTransactionMetrics aggregates the active and completed transactions
+// belonging to one skill
+func (c *vantageCollector) skillTransactionMetrics(skillID, skillName string, active, completed []Transaction) TransactionMetrics {
+	metrics := TransactionMetrics{
+		SkillID:            skillID,
+		SkillName:          skillName,
+		StageNameBreakdown: make(map[string]int),
+		StageTypeBreakdown: make(map[string]int),
+		StatusBreakdown:    make(map[string]int),
+		FileTypeBreakdown:  make(map[string]int),
+	}
 
-	for _, skillId := range skillIds {
-		if skillId == "" {
+	// Process active transactions for this skill
+	var totalPages, totalDocs int
+	for _, tx := range active {
+		if tx.SkillID != skillID {
 			continue
 		}
 
-		skillName := skillNames[skillId]
-		if skillName == "" {
-			skillName = skillId // fallback
+		metrics.TotalTransactions++
+		totalPages += tx.PageCount
+		totalDocs += tx.DocumentCount
+
+		// Stage breakdown, kept separate so a transaction counts once in each.
+		// A missing stage counts as unknown, as in vantage_active_transactions_by_stage.
+		stageName, stageType := tx.stage()
+		metrics.StageNameBreakdown[stageName]++
+		metrics.StageTypeBreakdown[stageType]++
+
+		// Count manual review vs processing
+		if inManualReview(tx) {
+			metrics.ActiveManualReview++
+		} else {
+			metrics.ActiveProcessing++
 		}
+	}
 
-		metrics := TransactionMetrics{
-			SkillID:           skillId,
-			SkillName:         skillName,
-			StageBreakdown:    make(map[string]int),
-			StatusBreakdown:   make(map[string]int),
-			FileTypeBreakdown: make(map[string]int),
+	// Process completed transactions for this skill
+	var processing processingAverage
+	for _, tx := range completed {
+		if tx.SkillID != skillID {
+			continue
 		}
 
-		// Process active transactions for this skill
-		var totalPages, totalDocs int
-		for _, tx := range activeTransactions {
-			if tx.SkillID != skillId {
-				continue
-			}
+		metrics.TotalTransactions++
+		totalPages += tx.PageCount
+		totalDocs += tx.DocumentCount
 
-			metrics.TotalTransactions++
-			totalPages += tx.PageCount
-			totalDocs += tx.DocumentCount
+		processing.add(tx)
 
-			// Stage breakdown
-			if tx.Stage.Name != "" {
-				metrics.StageBreakdown[tx.Stage.Name]++
-			}
-			if tx.Stage.Type != "" {
-				metrics.StageBreakdown[tx.Stage.Type]++
-			}
+		// Status breakdown
+		metrics.StatusBreakdown[tx.Status]++
 
-			// Count manual review vs processing
-			if tx.ManualReviewOperatorName != "" || tx.ManualReviewOperatorEmail != "" {
-				metrics.ActiveManualReview++
-			} else {
-				metrics.ActiveProcessing++
-			}
+		switch c.statuses.classify(tx.Status) {
+		case statusSuccess:
+			metrics.CompletedSuccess++
+		case statusFailed:
+			metrics.CompletedFailed++
 		}
+	}
 
-		// Process completed transactions for this skill
-		for _, tx := range completedTransactions {
-			if tx.SkillID != skillId {
-				continue
-			}
+	// Calculate averages
+	if metrics.TotalTransactions > 0 {
+		metrics.AveragePages = float64(totalPages) / float64(metrics.TotalTransactions)
+		metrics.AverageDocuments = float64(totalDocs) / float64(metrics.TotalTransactions)
+	}
+	metrics.AverageProcessing, _ = processing.mean()
+	metrics.ProcessingSamples = processing.samples
 
-			metrics.TotalTransactions++
-			totalPages += tx.PageCount
-			totalDocs += tx.DocumentCount
+	return metrics
+}
 
-			// Status breakdown
-			metrics.StatusBreakdown[tx.Status]++
+// ActiveTransaction is an active transaction as served by
+// /active-transactions, with its age computed at request time
+type ActiveTransaction struct {
+	Transaction
+	AgeSeconds *float64 `json:"age_seconds"`
+}
 
-			if tx.Status == "Finished Successfully" {
-				metrics.CompletedSuccess++
-			} else if tx.Status == "Failed" {
-				metrics.CompletedFailed++
-			}
+// handleActiveTransactions serves the raw active transaction list, optionally
+// restricted to the skills in the skills parameter
+func (c *vantageCollector) handleActiveTransactions(w http.ResponseWriter, r *http.Request) {
+	filter := c.skillFilter
+	if param := r.URL.Query().Get("skills"); param != "" {
+		skillIDs, err := parseSkillIDs(param)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+		filter = filter.narrow(skillIDs)
+	}
+
+	transactions, err := c.getActiveTransactions(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get active transactions: %v", err), http.StatusBadGateway)
+		return
+	}
 
-		// Calculate averages
-		if metrics.TotalTransactions > 0 {
-			metrics.AveragePages = float64(totalPages) / float64(metrics.TotalTransactions)
-			metrics.AverageDocuments = float64(totalDocs) / float64(metrics.TotalTransactions)
+	now := time.Now()
+	results := []ActiveTransaction{}
+	for _, tx := range filter.transactions(transactions) {
+		active := ActiveTransaction{Transaction: tx}
+		if created, ok := parseTimestamp(tx.ID, "createTimeUtc", tx.CreateTimeUtc); ok {
+			age := now.Sub(created).Seconds()
+			active.AgeSeconds = &age
 		}
+		results = append(results, active)
+	}
+
+	writeJSON(w, http.StatusOK, results)
+	log.Printf("Returned %d active transactions", len(results))
+}
 
-		results = append(results, metrics)
-		log.Printf("Processed skill %s (%s): %d total transactions", skillId, skillName, metrics.TotalTransactions)
+// transactionIDPattern matches the GUID-style IDs Vantage assigns
+var transactionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// handleTransaction serves /transaction/{id} with the transaction's detail,
+// including its result files and business rules errors
+func (c *vantageCollector) handleTransaction(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/transaction/")
+	if !transactionIDPattern.MatchString(id) {
+		http.Error(w, fmt.Sprintf("invalid transaction ID %q", id), http.StatusBadRequest)
+		return
 	}
 
-	// Return JSON response
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(results); err != nil {
-		http.Error(w, fmt.Sprintf("failed to encode response: %v", err), http.StatusInternalServerError)
+	detail, err := c.getTransactionDetail(r.Context(), id)
+	if err != nil {
+		var statusErr *apiStatusError
+		if errors.As(err, &statusErr) && statusErr.statusCode == http.StatusNotFound {
+			http.Error(w, fmt.Sprintf("transaction %s not found", id), http.StatusNotFound)
+			return
+		}
+		http.Error(w, fmt.Sprintf("failed to get transaction %s: %v", id, c.redactError(err)), http.StatusBadGateway)
 		return
 	}
 
-	log.Printf("Successfully returned metrics for %d skills", len(results))
+	writeJSON(w, http.StatusOK, detail)
+}
+
+// writeJSON encodes v as the JSON response body with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+// cachedGetSkills returns the skills list, refreshing it from the API once
+// the cache is older than skillsCacheTTL. Concurrent readers share the read
+// lock; a refresh takes the write lock so only one caller hits the API.
+func (c *vantageCollector) cachedGetSkills(ctx context.Context) ([]Skill, error) {
+	skills, _, _, err := c.loadSkills(ctx, false)
+	return skills, err
+}
+
+// loadSkills returns the skills list along with when it was fetched and
+// whether it came from the cache. force skips the cache and refreshes it.
+func (c *vantageCollector) loadSkills(ctx context.Context, force bool) ([]Skill, time.Time, bool, error) {
+	if !force {
+		c.skillsMu.RLock()
+		skills, fresh := c.freshSkillsLocked()
+		fetched := c.skillsCacheTime
+		c.skillsMu.RUnlock()
+		if fresh {
+			log.Printf("Using cached skills (%d skills)", len(skills))
+			return skills, fetched, true, nil
+		}
+	}
+
+	c.skillsMu.Lock()
+	defer c.skillsMu.Unlock()
+
+	// Another caller may have refreshed while we waited for the write lock
+	if !force {
+		if skills, fresh := c.freshSkillsLocked(); fresh {
+			return skills, c.skillsCacheTime, true, nil
+		}
+	}
+
+	skills, err := c.getSkills(ctx)
+	if errors.Is(err, errCircuitOpen) && !c.skillsCacheTime.IsZero() {
+		log.Printf("Using stale cached skills (%d skills): %v", len(c.cachedSkills), err)
+		return c.cachedSkills, c.skillsCacheTime, true, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	skills, c.typeExcluded = c.filterSkillTypes(skills)
+	c.cachedSkills = skills
+	c.skillsCacheTime = time.Now()
+	log.Printf("Refreshed skills cache (%d skills)", len(skills))
+	return skills, c.skillsCacheTime, false, nil
+}
+
+// freshSkillsLocked returns the cached skills and whether they were fetched
+// within the TTL. An empty list, e.g. with every skill type filtered out, is
+// as fresh as any other. The caller must hold skillsMu.
+func (c *vantageCollector) freshSkillsLocked() ([]Skill, bool) {
+	if !c.skillsCacheTime.IsZero() && time.Since(c.skillsCacheTime) < c.skillsCacheTTL {
+		return c.cachedSkills, true
+	}
+	return nil, false
 }
 
+// handleSkillsList serves the skills as Grafana template variable options.
+// ?refresh=true bypasses the cache, e.g. right after deploying a skill.
 func (c *vantageCollector) handleSkillsList(w http.ResponseWriter, r *http.Request) {
-	if time.Since(c.skillsCacheTime) < 5*time.Minute && len(c.cachedSkills) > 0 {
-		log.Printf("Using cached skills (%d skills)", len(c.cachedSkills))
+	refresh, _ := strconv.ParseBool(r.URL.Query().Get("refresh"))
+	skills, fetched, cached, err := c.loadSkills(r.Context(), refresh)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get skills: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if cached {
+		w.Header().Set("X-Cache", "HIT")
 	} else {
-		skills, err := c.getSkills()
-		if err != nil {
-			http.Error(w, fmt.Sprintf("failed to get skills: %v", err), http.StatusInternalServerError)
-			return
-		}
-		c.cachedSkills = skills
-		c.skillsCacheTime = time.Now()
-		log.Printf("Refreshed skills cache (%d skills)", len(skills))
+		w.Header().Set("X-Cache", "MISS")
 	}
+	w.Header().Set("Age", strconv.Itoa(int(time.Since(fetched).Seconds())))
 
 	type SkillOption struct {
 		Value string `json:"value"`
 		Text  string `json:"text"`
 	}
 
-	var options []SkillOption
-	for _, skill := range c.cachedSkills {
+	// An empty list must encode as [] rather than null for Grafana to parse it
+	options := []SkillOption{}
+	for _, skill := range skills {
 		options = append(options, SkillOption{
 			Value: skill.ID,
 			Text:  fmt.Sprintf("%s (%s)", skill.Name, skill.ID),
@@ -668,33 +2785,512 @@ func (c *vantageCollector) handleSkillsList(w http.ResponseWriter, r *http.Reque
 	log.Printf("Returned %d skills for template variables", len(options))
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
+// handleHealthz reports that the process is up
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+// checkReady succeeds once a token and skills fetch have succeeded and the
+// last successful upstream call is within the staleness window
+func (c *vantageCollector) checkReady(ctx context.Context) error {
+	if c.isReady() {
+		return nil
 	}
-	return b
+	// Nothing may be scraping us yet, so check the upstream directly before
+	// declaring the exporter unready
+	_, err := c.getSkills(ctx)
+	return err
 }
 
-func getEnv(key, defaultValue string) string {
+func (c *vantageCollector) isReady() bool {
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+
+	// An API key needs no token, so only the skills fetch counts there
+	if (c.authMode == authModeOAuth2 && c.lastTokenSuccess.IsZero()) || c.lastScrapeSuccess.IsZero() {
+		return false
+	}
+	return time.Since(c.lastScrapeSuccess) < c.readyStaleness
+}
+
+// lookupSetting returns the value of a setting from the environment, falling
+// back to the config file
+func lookupSetting(key string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
+	return fileConfig[key]
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := lookupSetting(key); value != "" {
+		return value
+	}
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	value := lookupSetting(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %d", value, key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	value := lookupSetting(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %t", value, key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvFloat parses a single number, falling back to the default when it is
+// unset or malformed
+func getEnvFloat(key string, defaultValue float64) float64 {
+	value := lookupSetting(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %g", value, key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// getEnvFloats parses a comma-separated list of numbers, such as histogram buckets
+func getEnvFloats(key string, defaultValue []float64) []float64 {
+	value := lookupSetting(key)
+	if value == "" {
+		return defaultValue
+	}
+	var parsed []float64
+	for _, part := range strings.Split(value, ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			log.Printf("Invalid value %q for %s, using default %v", value, key, defaultValue)
+			return defaultValue
+		}
+		parsed = append(parsed, f)
+	}
+	sort.Float64s(parsed)
+	return parsed
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value := lookupSetting(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid value %q for %s, using default %s", value, key, defaultValue)
+		return defaultValue
+	}
+	return parsed
+}
+
+// debugEnabled turns on verbose logging of skipped data and request details
+var debugEnabled bool
+
+func debugf(format string, args ...interface{}) {
+	if debugEnabled {
+		log.Printf("DEBUG "+format, args...)
+	}
+}
+
+// options holds the process-level settings that are not tied to a tenant
+type options struct {
+	port        string
+	tenantsFile string
+	metricsPath string
+	skillsPath  string
+	detailsPath string
+	auth        authConfig
+	push        pushConfig
+	// trustProxy honors the X-Forwarded-For and X-Forwarded-Proto headers
+	// of a reverse proxy in front of the exporter
+	trustProxy bool
+	// accessLog logs every request, not only with debug logging
+	accessLog bool
+}
+
+// fixedPaths are the endpoints whose paths can't be configured
+var fixedPaths = []string{
+	"/exporter-metrics", "/transaction/", "/active-transactions", "/business-rules-errors", "/skill-health",
+	"/healthz", "/readyz", "/version", "/config", "/search", "/query", "/annotations",
+}
+
+// validatePaths checks that the configurable endpoint paths are absolute and
+// distinct, since the mux panics on a duplicate registration
+func (o options) validatePaths() error {
+	seen := make(map[string]string)
+	for _, path := range fixedPaths {
+		seen[path] = "a built-in endpoint"
+	}
+	for _, p := range []struct{ name, path string }{
+		{"VANTAGE_METRICS_PATH", o.metricsPath},
+		{"VANTAGE_SKILLS_PATH", o.skillsPath},
+		{"VANTAGE_DETAILS_PATH", o.detailsPath},
+	} {
+		if !strings.HasPrefix(p.path, "/") || p.path == "/" {
+			return fmt.Errorf("%s %q must start with / and name an endpoint", p.name, p.path)
+		}
+		if other, ok := seen[p.path]; ok {
+			return fmt.Errorf("%s %q is already used by %s", p.name, p.path, other)
+		}
+		seen[p.path] = p.name
+	}
+	return nil
+}
+
+// flagValues holds command-line overrides. Empty values leave the setting
+// from the environment, config file or built-in default in place.
+type flagValues struct {
+	configFile   string
+	baseURL      string
+	clientID     string
+	clientSecret string
+	port         string
+	tenantsFile  string
+	validate     bool
+}
+
+func parseFlags() flagValues {
+	var f flagValues
+	flag.StringVar(&f.configFile, "config", "", "YAML configuration file (env VANTAGE_CONFIG_FILE)")
+	flag.StringVar(&f.baseURL, "base-url", "", "Vantage API base URL (env VANTAGE_BASE_URL)")
+	flag.StringVar(&f.clientID, "client-id", "", "Vantage API client ID (env VANTAGE_CLIENT_ID)")
+	flag.StringVar(&f.clientSecret, "client-secret", "", "Vantage API client secret (env VANTAGE_CLIENT_SECRET)")
+	flag.StringVar(&f.port, "port", "", "Port on which the exporter listens (env VANTAGE_METRICS_PORT, default 8080)")
+	flag.StringVar(&f.tenantsFile, "tenants-file", "", "JSON file listing tenants to collect; replaces the single-tenant settings (env VANTAGE_TENANTS_FILE)")
+	flag.BoolVar(&f.validate, "validate", false, "Check configuration and Vantage connectivity, print a summary and exit (env VANTAGE_VALIDATE)")
+	flag.Parse()
+	return f
+}
+
+// apply overrides the resolved configuration with any flags that were set
+func (f flagValues) apply(tenant *tenantConfig, opts *options) {
+	if f.baseURL != "" {
+		tenant.BaseURL = f.baseURL
+	}
+	if f.clientID != "" {
+		tenant.ClientID = f.clientID
+	}
+	if f.clientSecret != "" {
+		tenant.ClientSecret = f.clientSecret
+	}
+	if f.port != "" {
+		opts.port = f.port
+	}
+	if f.tenantsFile != "" {
+		opts.tenantsFile = f.tenantsFile
+	}
+}
+
+// newHTTPClient builds the client shared by all outbound Vantage requests.
+// Timeouts are applied per request via context so they can be tuned
+// independently for list and detail calls.
+func (c *vantageCollector) newHTTPClient() (*http.Client, error) {
+	proxy := http.ProxyFromEnvironment
+	if c.proxyURL != "" {
+		u, err := url.Parse(c.proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid VANTAGE_PROXY_URL %q: %w", c.proxyURL, err)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.tlsInsecure,
+	}
+	if c.tlsInsecure {
+		log.Println("WARNING: TLS certificate verification is disabled (VANTAGE_TLS_INSECURE)")
+	}
+	if c.caCertFile != "" {
+		pem, err := os.ReadFile(c.caCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read VANTAGE_CA_CERT: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("VANTAGE_CA_CERT %q contains no valid PEM certificates", c.caCertFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:               proxy,
+			TLSClientConfig:     tlsConfig,
+			MaxIdleConns:        10,
+			MaxIdleConnsPerHost: 10,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+		},
+	}, nil
+}
+
+// validate checks that the configuration is complete enough to reach Vantage
+func (c *vantageCollector) validate() error {
+	if c.baseURL == "" {
+		return fmt.Errorf("VANTAGE_BASE_URL must be set")
+	}
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return fmt.Errorf("VANTAGE_BASE_URL %q is not a valid URL: %w", c.baseURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("VANTAGE_BASE_URL %q must be an absolute http or https URL", c.baseURL)
+	}
+	switch c.authMode {
+	case authModeOAuth2:
+		if c.clientID == "" {
+			return fmt.Errorf("VANTAGE_CLIENT_ID must be set")
+		}
+		if c.clientSecret == "" {
+			return fmt.Errorf("VANTAGE_CLIENT_SECRET must be set")
+		}
+		if c.oauthScope == "" {
+			return fmt.Errorf("VANTAGE_OAUTH_SCOPE must not be blank")
+		}
+		if len(c.tokenPath) == 0 {
+			return fmt.Errorf("VANTAGE_TOKEN_PATH must name the token endpoint")
+		}
+	case authModeAPIKey:
+		if c.apiKey == "" {
+			return fmt.Errorf("VANTAGE_API_KEY must be set with VANTAGE_AUTH_MODE=apikey")
+		}
+		if strings.TrimSpace(c.apiKeyHeader) == "" {
+			return fmt.Errorf("VANTAGE_API_KEY_HEADER must not be blank")
+		}
+	default:
+		return fmt.Errorf("VANTAGE_AUTH_MODE %q must be %s or %s", c.authMode, authModeOAuth2, authModeAPIKey)
+	}
+	if c.proxyURL != "" {
+		u, err := url.Parse(c.proxyURL)
+		if err != nil || u.Host == "" {
+			return fmt.Errorf("VANTAGE_PROXY_URL %q must be an absolute URL", c.proxyURL)
+		}
+	}
+	return nil
+}
+
+// newRuntimeRegistry returns the registry of the Go runtime, process and
+// build metrics, which are served alongside both the business and the
+// exporter metrics
+func newRuntimeRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		newBuildInfo(),
+	)
+	return registry
+}
+
 func main() {
-	collector := newVantageCollector()
-	prometheus.MustRegister(collector)
+	flags := parseFlags()
+
+	configFile := flags.configFile
+	if configFile == "" {
+		configFile = os.Getenv("VANTAGE_CONFIG_FILE")
+	}
+	if configFile != "" {
+		if err := loadConfigFile(configFile); err != nil {
+			log.Fatalf("Invalid config file: %v", err)
+		}
+	}
+	debugEnabled = getEnvBool("VANTAGE_DEBUG", false)
+	logConfigSources()
+
+	tenant := defaultTenant()
+	opts := options{
+		port:        getEnv("VANTAGE_METRICS_PORT", "8080"),
+		tenantsFile: getEnv("VANTAGE_TENANTS_FILE", ""),
+		metricsPath: getEnv("VANTAGE_METRICS_PATH", "/metrics"),
+		skillsPath:  getEnv("VANTAGE_SKILLS_PATH", "/skills"),
+		detailsPath: getEnv("VANTAGE_DETAILS_PATH", "/transaction-details"),
+		push: pushConfig{
+			url:      getEnv("VANTAGE_PUSHGATEWAY_URL", ""),
+			job:      getEnv("VANTAGE_PUSHGATEWAY_JOB", "vantage-exporter"),
+			interval: max(getEnvDuration("VANTAGE_PUSHGATEWAY_INTERVAL", time.Minute), time.Second),
+		},
+		auth: authConfig{
+			token:    getEnv("VANTAGE_AUTH_TOKEN", ""),
+			user:     getEnv("VANTAGE_AUTH_USER", ""),
+			password: getEnv("VANTAGE_AUTH_PASS", ""),
+		},
+		trustProxy: getEnvBool("VANTAGE_TRUST_PROXY", false),
+		accessLog:  getEnvBool("VANTAGE_ACCESS_LOG", false),
+	}
+	opts.auth.trustProxy = opts.trustProxy
+	flags.apply(&tenant, &opts)
+	if err := opts.validatePaths(); err != nil {
+		log.Fatalf("Invalid endpoint path: %v", err)
+	}
+	if err := opts.auth.validate(); err != nil {
+		log.Fatalf("Invalid authentication settings: %v", err)
+	}
+	if opts.push.url != "" {
+		grouping, err := parseGrouping(getEnv("VANTAGE_PUSHGATEWAY_GROUPING", ""))
+		if err != nil {
+			log.Fatalf("Invalid VANTAGE_PUSHGATEWAY_GROUPING: %v", err)
+		}
+		opts.push.grouping = grouping
+	}
+
+	tenants := []tenantConfig{tenant}
+	if opts.tenantsFile != "" {
+		var err error
+		if tenants, err = loadTenantsFile(opts.tenantsFile); err != nil {
+			log.Fatalf("Invalid tenants file: %v", err)
+		}
+	} else if len(fileTenants) > 0 {
+		tenants = fileTenants
+	}
+
+	// Vantage data and the exporter's own health are served from separate
+	// registries so they can be scraped at different intervals. Runtime and
+	// build metrics are served with both, /metrics having always carried them.
+	registry := prometheus.NewRegistry()
+	selfRegistry := prometheus.NewRegistry()
+	runtimeRegistry := newRuntimeRegistry()
+	self := newSelfMetrics()
+	selfRegistry.MustRegister(self.collectors()...)
+
+	var collectors []*vantageCollector
+	for _, t := range tenants {
+		collector := newVantageCollector(t, self)
+		if err := collector.validate(); err != nil {
+			log.Fatalf("Invalid configuration for tenant %q: %v", t.Name, err)
+		}
+		httpClient, err := collector.newHTTPClient()
+		if err != nil {
+			log.Fatalf("Failed to configure HTTP client for tenant %q: %v", t.Name, err)
+		}
+		collector.httpClient = httpClient
+		registry.MustRegister(collector)
+		selfRegistry.MustRegister(collector.cacheMetrics()...)
+		collectors = append(collectors, collector)
+	}
+	if flags.validate || getEnvBool("VANTAGE_VALIDATE", false) {
+		os.Exit(validate(collectors))
+	}
 
-	http.Handle("/metrics", promhttp.Handler())
-	http.HandleFunc("/transaction-details", collector.handleTransactionDetails)
-	http.HandleFunc("/skills", collector.handleSkillsList)
+	router := newTenantRouter(collectors)
 
-	log.Printf("Vantage exporter running on :%s", collector.port)
+	// Diagnose credential and token path problems up front rather than on
+	// the first scrape, without holding up startup
+	go func() {
+		for _, c := range collectors {
+			c.checkToken(context.Background())
+		}
+	}()
+
+	http.Handle(opts.metricsPath, metricsHandler(registry, runtimeRegistry, collectors))
+	http.Handle("/exporter-metrics", promhttp.HandlerFor(prometheus.Gatherers{selfRegistry, runtimeRegistry}, promhttp.HandlerOpts{}))
+	http.HandleFunc(opts.detailsPath, router.handle((*vantageCollector).handleTransactionDetails))
+	http.HandleFunc(opts.skillsPath, router.handle((*vantageCollector).handleSkillsList))
+	http.HandleFunc("/transaction/", router.handle((*vantageCollector).handleTransaction))
+	http.HandleFunc("/active-transactions", router.handle((*vantageCollector).handleActiveTransactions))
+	http.HandleFunc("/business-rules-errors", router.handle((*vantageCollector).handleBusinessRulesErrors))
+	http.HandleFunc("/skill-health", router.handle((*vantageCollector).handleSkillHealth))
+	http.HandleFunc("/healthz", handleHealthz)
+	http.HandleFunc("/version", handleVersion)
+	http.HandleFunc("/config", configHandler(opts, collectors))
+	http.HandleFunc("/readyz", router.handleReadyz)
+	http.HandleFunc("/", handleSimpleJSONRoot)
+	http.HandleFunc("/search", router.handle((*vantageCollector).handleSearch))
+	http.HandleFunc("/query", router.handle((*vantageCollector).handleQuery))
+	http.HandleFunc("/annotations", router.handle((*vantageCollector).handleAnnotations))
+
+	log.Printf("Vantage exporter %s running on :%s for %d tenant(s)", version, opts.port, len(collectors))
 	log.Println("Endpoints:")
-	log.Println("  /metrics - Prometheus metrics")
-	log.Println("  /transaction-details?skills=skill1,skill2,skill3 - Multi-skill transaction details")
-	log.Println("  /skills - Skills list for Grafana template variables")
+	log.Printf("  %s - Prometheus metrics (optional ?skills=skill1,skill2 filter)", opts.metricsPath)
+	log.Println("  /exporter-metrics - The exporter's own health metrics")
+	log.Printf("  %s?skills=skill1,skill2,skill3 - Multi-skill transaction details", opts.detailsPath)
+	log.Printf("  %s - Skills list for Grafana template variables", opts.skillsPath)
+	log.Println("  /transaction/{id} - Detail of a single transaction")
+	log.Println("  /active-transactions?skills=skill1,skill2 - Active transactions with their age")
+	log.Println("  /business-rules-errors?skills=skill1,skill2&top=10 - Most frequent business rule errors")
+	log.Println("  /skill-health?skills=skill1,skill2 - Success rate, failure rate, active count and processing time per skill")
+	log.Println("  /healthz - Liveness probe")
+	log.Println("  /readyz - Readiness probe")
+	log.Println("  /version - Build version, commit and date")
+	log.Println("  /config - Effective configuration, secrets redacted")
+	log.Println("  /search, /query, /annotations - Grafana SimpleJSON datasource")
+	if len(collectors) > 1 {
+		log.Printf("  Pass ?tenant=<name> to %s, %s, /transaction/{id}, /active-transactions, /business-rules-errors, /skill-health and the SimpleJSON endpoints to select a tenant", opts.skillsPath, opts.detailsPath)
+	}
+	if opts.auth.enabled() {
+		log.Println("Authentication required on all endpoints except /healthz and /readyz")
+	}
+
+	corsOrigins := getEnv("VANTAGE_CORS_ORIGINS", "")
+	cors := newCORSConfig(corsOrigins,
+		opts.skillsPath, opts.detailsPath, "/transaction/", "/active-transactions", "/business-rules-errors",
+		"/skill-health", "/version", "/search", "/query", "/annotations",
+	)
+	if cors.enabled() {
+		log.Printf("CORS enabled on the JSON endpoints for origins %s", corsOrigins)
+	}
+
+	server := &http.Server{
+		Addr:              ":" + opts.port,
+		Handler:           logRequests(cors.allowCORS(opts.auth.requireAuth(http.DefaultServeMux)), opts.accessLog, opts.trustProxy),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("HTTP server failed: %v", err)
+		}
+	}()
+
+	for _, c := range collectors {
+		if c.collectInterval > 0 {
+			log.Printf("Collecting tenant %s every %s in the background", c.tenant, c.collectInterval)
+			go c.runBackgroundCollection(ctx)
+		}
+	}
+
+	if opts.push.url != "" {
+		log.Printf("Pushing metrics to %s as job %q every %s", opts.push.url, opts.push.job, opts.push.interval)
+		go runPush(ctx, opts.push, prometheus.Gatherers{registry, selfRegistry, runtimeRegistry}, self.pushFailures)
+	}
+
+	<-ctx.Done()
+	stop()
 
-	log.Fatal(http.ListenAndServe(":"+collector.port, nil))
+	shutdownTimeout := getEnvDuration("VANTAGE_SHUTDOWN_TIMEOUT", 30*time.Second)
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Graceful shutdown failed: %v", err)
+		return
+	}
+	log.Println("Shutdown complete")
 }
\ No newline at end of file
@@ -0,0 +1,567 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// fakeAPI serves canned Vantage API responses. Routes are keyed by the last
+// path element: "token", "skills", "active", "completed", or a transaction
+// ID for the detail endpoint. Unrouted lists are empty.
+type fakeAPI map[string]http.HandlerFunc
+
+func (f fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	route := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	if handler, ok := f[route]; ok {
+		handler(w, r)
+		return
+	}
+	switch route {
+	case "token":
+		respond(http.StatusOK, `{"access_token":"tok","expires_in":3600}`)(w, r)
+	case "skills":
+		respond(http.StatusOK, `[]`)(w, r)
+	case "active", "completed":
+		respond(http.StatusOK, `{"items":[],"totalItemCount":0}`)(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// respond answers with a fixed status and body
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}
+}
+
+// respondJSON answers 200 with v encoded as JSON
+func respondJSON(t *testing.T, v interface{}) http.HandlerFunc {
+	t.Helper()
+	body, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return respond(http.StatusOK, string(body))
+}
+
+// transactionList wraps transactions in a list response
+func transactionList(transactions ...Transaction) TransactionResponse {
+	return TransactionResponse{Items: transactions, TotalItemCount: len(transactions)}
+}
+
+// newTestCollector returns a collector for tenant "test" backed by api.
+// Settings are read from the environment, so tests set them with t.Setenv
+// before calling it.
+func newTestCollector(t *testing.T, api fakeAPI) *vantageCollector {
+	t.Helper()
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+
+	c := newVantageCollector(tenantConfig{
+		Name:         "test",
+		BaseURL:      server.URL,
+		ClientID:     "client",
+		ClientSecret: "s3cr3t-value",
+	}, newSelfMetrics())
+	c.httpClient = server.Client()
+	return c
+}
+
+// compareMetrics checks the named metric families the collector emits
+// against the expected exposition text
+func compareMetrics(t *testing.T, c *vantageCollector, expected string, names ...string) {
+	t.Helper()
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), names...); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCollectFromAPI(t *testing.T) {
+	c := newTestCollector(t, fakeAPI{
+		"skills": respondJSON(t, []Skill{{ID: "s1", Name: "Invoice", Type: "Document"}}),
+		"active": respondJSON(t, transactionList(
+			Transaction{ID: "a1", SkillID: "s1", Status: "Processing", CreateTimeUtc: "2026-10-14T10:00:00Z"},
+			Transaction{ID: "a2", SkillID: "s1", Status: "Processing", CreateTimeUtc: "2026-10-14T10:00:00Z"},
+		)),
+		"completed": respondJSON(t, transactionList(
+			Transaction{ID: "c1", SkillID: "s1", Status: "Finished Successfully", CreateTimeUtc: "2026-10-14T10:00:00Z", CompletedUtc: "2026-10-14T10:01:00Z"},
+		)),
+	})
+
+	compareMetrics(t, c, `
+# HELP vantage_skill_info Vantage skill information
+# TYPE vantage_skill_info gauge
+vantage_skill_info{skill_id="s1",skill_name="Invoice",skill_type="Document",tenant="test"} 1
+# HELP vantage_active_transactions_total Active transactions as reported by the API's TotalItemCount, across all skills and regardless of VANTAGE_MAX_PAGES
+# TYPE vantage_active_transactions_total gauge
+vantage_active_transactions_total{tenant="test"} 2
+# HELP vantage_completed_transactions_available Completed transactions within the lookback as reported by the API's TotalItemCount, across all skills and regardless of VANTAGE_MAX_PAGES
+# TYPE vantage_completed_transactions_available gauge
+vantage_completed_transactions_available{tenant="test"} 1
+`, "vantage_skill_info", "vantage_active_transactions_total", "vantage_completed_transactions_available")
+}
+
+func TestCollectEmptyBodies(t *testing.T) {
+	c := newTestCollector(t, fakeAPI{
+		"skills":    respond(http.StatusOK, ""),
+		"active":    respond(http.StatusOK, ""),
+		"completed": respond(http.StatusOK, ""),
+	})
+
+	if n := testutil.CollectAndCount(c, "vantage_skill_info", "vantage_active_transaction"); n != 0 {
+		t.Errorf("got %d series from empty responses, want 0", n)
+	}
+	for _, endpoint := range []string{"skills", "active", "completed"} {
+		if got := testutil.ToFloat64(c.self.scrapeErrors.WithLabelValues("test", endpoint)); got != 0 {
+			t.Errorf("scrape errors for %s = %v, want 0", endpoint, got)
+		}
+	}
+}
+
+func TestCollectAPIErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"non-200 status", respond(http.StatusForbidden, `{"error":"forbidden"}`)},
+		{"malformed JSON", respond(http.StatusOK, `{"items":[`)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestCollector(t, fakeAPI{
+				"skills":    tc.handler,
+				"active":    tc.handler,
+				"completed": tc.handler,
+			})
+
+			if n := testutil.CollectAndCount(c, "vantage_skill_info", "vantage_active_transactions_total", "vantage_completed_transactions_total"); n != 0 {
+				t.Errorf("got %d series from failed fetches, want 0", n)
+			}
+			for _, endpoint := range []string{"skills", "active", "completed"} {
+				if got := testutil.ToFloat64(c.self.scrapeErrors.WithLabelValues("test", endpoint)); got != 1 {
+					t.Errorf("scrape errors for %s = %v, want 1", endpoint, got)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleSkillsList(t *testing.T) {
+	c := newTestCollector(t, fakeAPI{
+		"skills": respondJSON(t, []Skill{{ID: "s1", Name: "Invoice"}, {ID: "s2", Name: "Receipt"}}),
+	})
+
+	rec := httptest.NewRecorder()
+	c.handleSkillsList(rec, httptest.NewRequest(http.MethodGet, "/skills", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var options []struct{ Value, Text string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &options); err != nil {
+		t.Fatal(err)
+	}
+	if len(options) != 2 {
+		t.Errorf("got %d skill options, want 2: %s", len(options), rec.Body)
+	}
+}
+
+func TestResponseTooLarge(t *testing.T) {
+	t.Setenv("VANTAGE_MAX_RESPONSE_BYTES", "64")
+	skills := make([]Skill, 10)
+	for i := range skills {
+		skills[i] = Skill{ID: "skill", Name: "A skill with a long enough name"}
+	}
+	c := newTestCollector(t, fakeAPI{"skills": respondJSON(t, skills)})
+
+	_, err := c.getSkills(context.Background())
+	if !errors.Is(err, errResponseTooLarge) {
+		t.Fatalf("getSkills error = %v, want %v", err, errResponseTooLarge)
+	}
+	if !strings.Contains(err.Error(), "response too large") {
+		t.Errorf("error %q does not say the response is too large", err)
+	}
+}
+
+func TestCollectedMetricValues(t *testing.T) {
+	completed := func(id, skillID, status string, version int) Transaction {
+		return Transaction{ID: id, SkillID: skillID, SkillVersion: version, Status: status,
+			CreateTimeUtc: "2026-10-14T10:00:00Z", CompletedUtc: "2026-10-14T10:05:00Z"}
+	}
+	c := newTestCollector(t, fakeAPI{
+		"skills": respondJSON(t, []Skill{
+			{ID: "s1", Name: "Invoice", Type: "Document"},
+			{ID: "s2", Name: "Receipt", Type: "Classification"},
+		}),
+		"active": respondJSON(t, transactionList(
+			Transaction{ID: "a1", SkillID: "s1", SkillVersion: 3, Status: "Processing", CreateTimeUtc: "2026-10-14T10:00:00Z"},
+		)),
+		"completed": respondJSON(t, transactionList(
+			completed("c1", "s1", "Finished Successfully", 1),
+			completed("c2", "s1", "Finished Successfully", 2),
+			completed("c3", "s1", "Failed", 2),
+		)),
+	})
+
+	expected := `
+# HELP vantage_skill_info Vantage skill information
+# TYPE vantage_skill_info gauge
+vantage_skill_info{skill_id="s1",skill_name="Invoice",skill_type="Document",tenant="test"} 1
+vantage_skill_info{skill_id="s2",skill_name="Receipt",skill_type="Classification",tenant="test"} 1
+# HELP vantage_completed_transactions_total Completed transactions seen since the exporter started by skill, raw status (other when outside VANTAGE_COUNTED_STATUSES) and normalized status category. Each transaction is counted once across scrapes
+# TYPE vantage_completed_transactions_total counter
+vantage_completed_transactions_total{category="failed",skill_id="s1",status="Failed",tenant="test"} 1
+vantage_completed_transactions_total{category="success",skill_id="s1",status="Finished Successfully",tenant="test"} 2
+# HELP vantage_skill_version_info Newest skill version seen in a transaction, per known skill
+# TYPE vantage_skill_version_info gauge
+vantage_skill_version_info{skill_id="s1",skill_name="Invoice",tenant="test",version="3"} 1
+vantage_skill_version_info{skill_id="s2",skill_name="Receipt",tenant="test",version="unknown"} 1
+`
+	names := []string{"vantage_skill_info", "vantage_completed_transactions_total", "vantage_skill_version_info"}
+	compareMetrics(t, c, expected, names...)
+	// A second scrape re-fetches the same completions without counting them again
+	compareMetrics(t, c, expected, names...)
+}
+
+// TestSkillsCacheConcurrentAccess exercises the skills cache from scrapes
+// and /skills requests at once; run with -race to catch unsynchronized access
+func TestSkillsCacheConcurrentAccess(t *testing.T) {
+	// A tiny TTL makes most calls refresh the cache while others read it
+	t.Setenv("VANTAGE_SKILLS_CACHE_TTL", "1ms")
+	c := newTestCollector(t, fakeAPI{
+		"skills": respondJSON(t, []Skill{{ID: "s1", Name: "Invoice"}}),
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			testutil.CollectAndCount(c, "vantage_skill_info")
+		}()
+		go func() {
+			defer wg.Done()
+			c.handleSkillsList(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/skills?refresh=true", nil))
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := c.cachedGetSkills(context.Background()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestStageBreakdownsSumToActive(t *testing.T) {
+	c := newTestCollector(t, fakeAPI{})
+	active := []Transaction{
+		{ID: "a1", SkillID: "s1", Stage: &StageDto{Name: "Extract", Type: "Processing"}},
+		{ID: "a2", SkillID: "s1", Stage: &StageDto{Name: "Review", Type: "ManualReview"}},
+		// A stage whose name equals another stage's type must not collide
+		{ID: "a3", SkillID: "s1", Stage: &StageDto{Name: "Processing", Type: "Processing"}},
+		{ID: "a4", SkillID: "s1"},
+	}
+
+	metrics := c.skillTransactionMetrics("s1", "Invoice", active, nil)
+	for name, breakdown := range map[string]map[string]int{
+		"stage_name_breakdown": metrics.StageNameBreakdown,
+		"stage_type_breakdown": metrics.StageTypeBreakdown,
+	} {
+		sum := 0
+		for _, n := range breakdown {
+			sum += n
+		}
+		if sum != len(active) {
+			t.Errorf("%s %v sums to %d, want %d active transactions", name, breakdown, sum, len(active))
+		}
+	}
+	if got := metrics.StageNameBreakdown["Processing"]; got != 1 {
+		t.Errorf("stage name Processing counted %d times, want 1", got)
+	}
+}
+
+func TestTransactionDetailURLEscaping(t *testing.T) {
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/token") {
+			respond(http.StatusOK, `{"access_token":"tok","expires_in":3600}`)(w, r)
+			return
+		}
+		gotPath = r.URL.EscapedPath()
+		respond(http.StatusOK, `{"id":"x","status":"Processing"}`)(w, r)
+	}))
+	defer server.Close()
+
+	// A trailing slash on the base URL must not double up
+	c := newVantageCollector(tenantConfig{Name: "test", BaseURL: server.URL + "/", ClientID: "client", ClientSecret: "secret"}, newSelfMetrics())
+	c.httpClient = server.Client()
+
+	for id, want := range map[string]string{
+		"a/b":    "/api/publicapi/v1/transactions/a%2Fb",
+		"a b":    "/api/publicapi/v1/transactions/a%20b",
+		"a?b#c":  "/api/publicapi/v1/transactions/a%3Fb%23c",
+		"plain1": "/api/publicapi/v1/transactions/plain1",
+	} {
+		if _, err := c.getTransactionDetail(context.Background(), id); err != nil {
+			t.Errorf("getTransactionDetail(%q): %v", id, err)
+			continue
+		}
+		if gotPath != want {
+			t.Errorf("transaction %q requested %s, want %s", id, gotPath, want)
+		}
+	}
+}
+
+func TestSkillsCacheTTL(t *testing.T) {
+	t.Setenv("VANTAGE_SKILLS_CACHE_TTL", "2m")
+	var fetches atomic.Int32
+	skills := respondJSON(t, []Skill{{ID: "s1", Name: "Invoice"}})
+	c := newTestCollector(t, fakeAPI{
+		"skills": func(w http.ResponseWriter, r *http.Request) {
+			fetches.Add(1)
+			skills(w, r)
+		},
+	})
+	if c.skillsCacheTTL != 2*time.Minute {
+		t.Fatalf("skillsCacheTTL = %s, want 2m", c.skillsCacheTTL)
+	}
+
+	// age moves the last fetch back by d and loads the skills
+	age := func(d time.Duration) {
+		c.skillsMu.Lock()
+		c.skillsCacheTime = time.Now().Add(-d)
+		c.skillsMu.Unlock()
+		if _, err := c.cachedGetSkills(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := c.cachedGetSkills(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	age(2*time.Minute - time.Second)
+	if n := fetches.Load(); n != 1 {
+		t.Fatalf("skills fetched %d times before the TTL elapsed, want 1", n)
+	}
+	age(2 * time.Minute)
+	if n := fetches.Load(); n != 2 {
+		t.Fatalf("skills fetched %d times once the TTL elapsed, want 2", n)
+	}
+	// The refresh starts a new TTL
+	if _, err := c.cachedGetSkills(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("skills fetched %d times right after a refresh, want 2", n)
+	}
+}
+
+func TestEmptyListsEncodeAsArrays(t *testing.T) {
+	// With both transaction fetches failing no skill has details to report
+	c := newTestCollector(t, fakeAPI{
+		"active":    respond(http.StatusInternalServerError, ""),
+		"completed": respond(http.StatusInternalServerError, ""),
+	})
+
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		target  string
+		status  int
+		field   string
+	}{
+		{"skills", c.handleSkillsList, "/skills", http.StatusOK, ""},
+		{"transaction details", c.handleTransactionDetails, "/transaction-details?skills=s1", http.StatusBadGateway, "data"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tc.handler(rec, httptest.NewRequest(http.MethodGet, tc.target, nil))
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.status, rec.Body)
+			}
+			body := rec.Body.Bytes()
+			if tc.field != "" {
+				var response map[string]json.RawMessage
+				if err := json.Unmarshal(body, &response); err != nil {
+					t.Fatal(err)
+				}
+				body = response[tc.field]
+			}
+			if got := strings.TrimSpace(string(body)); got != "[]" {
+				t.Errorf("got %s, want []", got)
+			}
+		})
+	}
+}
+
+func TestMissingStageAndOperator(t *testing.T) {
+	// No stage, an empty stage object, a null stage and blank operator fields
+	c := newTestCollector(t, fakeAPI{
+		"skills": respondJSON(t, []Skill{{ID: "s1", Name: "Invoice"}}),
+		"active": respond(http.StatusOK, `{"items":[
+			{"transactionId":"a1","skillId":"s1","status":"Processing"},
+			{"transactionId":"a2","skillId":"s1","status":"Processing","stage":{}},
+			{"transactionId":"a3","skillId":"s1","status":"Processing","stage":null,"manualReviewOperatorName":"  ","manualReviewOperatorEmail":""}
+		],"totalItemCount":3}`),
+	})
+
+	compareMetrics(t, c, `
+# HELP vantage_active_manual_review Active transactions assigned to manual review by skill
+# TYPE vantage_active_manual_review gauge
+vantage_active_manual_review{skill_id="s1",tenant="test"} 0
+# HELP vantage_active_processing Active transactions being processed automatically by skill
+# TYPE vantage_active_processing gauge
+vantage_active_processing{skill_id="s1",tenant="test"} 3
+# HELP vantage_active_transactions_by_stage Active transactions by skill and processing stage
+# TYPE vantage_active_transactions_by_stage gauge
+vantage_active_transactions_by_stage{skill_id="s1",stage_name="unknown",stage_type="unknown",tenant="test"} 3
+`, "vantage_active_processing", "vantage_active_manual_review", "vantage_manual_review_assigned", "vantage_active_transactions_by_stage")
+
+	rec := httptest.NewRecorder()
+	c.handleTransactionDetails(rec, httptest.NewRequest(http.MethodGet, "/transaction-details?skills=s1", nil))
+	var response TransactionDetailsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Data) != 1 {
+		t.Fatalf("got %d skills, want 1: %s", len(response.Data), rec.Body)
+	}
+	metrics := response.Data[0]
+	if metrics.ActiveProcessing != 3 || metrics.ActiveManualReview != 0 {
+		t.Errorf("active processing/manual review = %d/%d, want 3/0", metrics.ActiveProcessing, metrics.ActiveManualReview)
+	}
+	if got := metrics.StageNameBreakdown[unknownStage]; got != 3 {
+		t.Errorf("stage name breakdown %v, want 3 %s", metrics.StageNameBreakdown, unknownStage)
+	}
+	if got := metrics.StageTypeBreakdown[unknownStage]; got != 3 {
+		t.Errorf("stage type breakdown %v, want 3 %s", metrics.StageTypeBreakdown, unknownStage)
+	}
+}
+
+func TestDisabledMetrics(t *testing.T) {
+	t.Setenv("VANTAGE_DISABLED_METRICS", "vantage_skill_info, "+processingDurationName+",vantage_no_such_metric")
+	c := newTestCollector(t, fakeAPI{
+		"skills": respondJSON(t, []Skill{{ID: "s1", Name: "Invoice"}}),
+		"completed": respondJSON(t, transactionList(
+			Transaction{ID: "c1", SkillID: "s1", Status: "Finished Successfully", CreateTimeUtc: "2026-10-14T10:00:00Z", CompletedUtc: "2026-10-14T10:01:00Z"},
+		)),
+	})
+	disabled := []string{"vantage_skill_info", processingDurationName}
+
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(descs)
+		close(descs)
+	}()
+	for desc := range descs {
+		for _, name := range disabled {
+			if strings.Contains(desc.String(), `fqName: "`+name+`"`) {
+				t.Errorf("Describe sent disabled %s", name)
+			}
+		}
+	}
+
+	for _, name := range disabled {
+		if n := testutil.CollectAndCount(c, name); n != 0 {
+			t.Errorf("got %d %s series, want 0", n, name)
+		}
+	}
+	if n := testutil.CollectAndCount(c, "vantage_completed_transactions_total"); n != 1 {
+		t.Errorf("got %d series of an enabled metric, want 1", n)
+	}
+}
+
+func TestStageUnmarshal(t *testing.T) {
+	for _, tc := range []struct {
+		stage    string
+		wantName string
+		wantType string
+	}{
+		{`{"type":"Processing","name":"Extract"}`, "Extract", "Processing"},
+		{`{"name":"Extract"}`, "Extract", unknownStage},
+		{`"Extract"`, "Extract", unknownStage},
+		{`""`, unknownStage, unknownStage},
+		{`null`, unknownStage, unknownStage},
+	} {
+		var tx Transaction
+		if err := json.Unmarshal([]byte(`{"transactionId":"t1","stage":`+tc.stage+`}`), &tx); err != nil {
+			t.Errorf("unmarshal stage %s: %v", tc.stage, err)
+			continue
+		}
+		tx.normalize()
+		if name, stageType := tx.stage(); name != tc.wantName || stageType != tc.wantType {
+			t.Errorf("stage %s = %q/%q, want %q/%q", tc.stage, name, stageType, tc.wantName, tc.wantType)
+		}
+	}
+
+	var tx Transaction
+	if err := json.Unmarshal([]byte(`{"stage":42}`), &tx); err == nil {
+		t.Error("unmarshal numeric stage succeeded, want an error")
+	}
+}
+
+func TestDetailFetchesSkipCachedDetails(t *testing.T) {
+	t.Setenv("VANTAGE_ENABLE_DETAIL_METRICS", "true")
+	t.Setenv("VANTAGE_DETAIL_MAX", "1")
+	var fetches atomic.Int32
+	detail := func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		respondJSON(t, TransactionDetail{ID: id, Status: "Finished Successfully"})(w, r)
+	}
+	completed := func(id string) Transaction {
+		return Transaction{ID: id, SkillID: "s1", Status: "Finished Successfully", CreateTimeUtc: "2026-10-14T10:00:00Z", CompletedUtc: "2026-10-14T10:01:00Z"}
+	}
+	c := newTestCollector(t, fakeAPI{
+		"completed": respondJSON(t, transactionList(completed("c1"), completed("c2"))),
+		"c1":        detail,
+		"c2":        detail,
+	})
+
+	// Each scrape fetches one uncached detail; cached ones are skipped
+	// rather than fetched again or counted against VANTAGE_DETAIL_MAX
+	for scrape, want := range []map[string]int{
+		{"fetched": 1, "skipped": 0, "capped": 1},
+		{"fetched": 1, "skipped": 1, "capped": 0},
+		{"fetched": 0, "skipped": 2, "capped": 0},
+	} {
+		expected := `
+# HELP vantage_detail_fetches Completed transactions by outcome of their detail fetch in the last scrape: fetched, failed, skipped as already counted or held in the detail cache, or capped by VANTAGE_DETAIL_MAX
+# TYPE vantage_detail_fetches gauge
+`
+		for _, outcome := range []string{"capped", "failed", "fetched", "skipped"} {
+			expected += `vantage_detail_fetches{outcome="` + outcome + `",tenant="test"} ` + strconv.Itoa(want[outcome]) + "\n"
+		}
+		if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "vantage_detail_fetches"); err != nil {
+			t.Errorf("scrape %d: %v", scrape+1, err)
+		}
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("details fetched %d times, want 2", n)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// clientAddr returns the address of the client that sent r. With trustProxy
+// the last X-Forwarded-For entry is used, which is the one added by the
+// proxy in front of the exporter; entries before it are client-supplied and
+// can't be trusted. A proxy may add its own header line rather than append
+// to the client's, so the entry is taken from the last line.
+func clientAddr(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if lines := r.Header.Values("X-Forwarded-For"); len(lines) > 0 {
+			entries := strings.Split(lines[len(lines)-1], ",")
+			if addr := strings.TrimSpace(entries[len(entries)-1]); addr != "" {
+				return addr
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// requestScheme returns the scheme the client used, which with trustProxy
+// is the proxy's X-Forwarded-Proto when TLS is terminated in front of the
+// exporter
+func requestScheme(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+			return strings.ToLower(strings.TrimSpace(strings.Split(proto, ",")[0]))
+		}
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientAddr(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		forwarded []string
+		trust     bool
+		want      string
+	}{
+		{"untrusted ignores header", []string{"203.0.113.7"}, false, "192.0.2.1"},
+		{"no header", nil, true, "192.0.2.1"},
+		{"single line", []string{"198.51.100.2, 203.0.113.7"}, true, "203.0.113.7"},
+		{"proxy adds its own line", []string{"198.51.100.66, 10.0.0.1", "203.0.113.7"}, true, "203.0.113.7"},
+		{"empty last entry", []string{"203.0.113.7, "}, true, "192.0.2.1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/metrics", nil)
+			r.RemoteAddr = "192.0.2.1:4711"
+			for _, line := range tc.forwarded {
+				r.Header.Add("X-Forwarded-For", line)
+			}
+			if got := clientAddr(r, tc.trust); got != tc.want {
+				t.Errorf("clientAddr = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
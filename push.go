@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// pushConfig describes optional pushing to a Prometheus Pushgateway, for
+// deployments that can't be scraped directly
+type pushConfig struct {
+	url      string
+	job      string
+	interval time.Duration
+	grouping map[string]string
+}
+
+// parseGrouping parses a comma-separated list of label=value pairs
+func parseGrouping(value string) (map[string]string, error) {
+	grouping := make(map[string]string)
+	for _, pair := range splitList(value) {
+		name, labelValue, ok := strings.Cut(pair, "=")
+		name, labelValue = strings.TrimSpace(name), strings.TrimSpace(labelValue)
+		if !ok || name == "" || labelValue == "" {
+			return nil, fmt.Errorf("invalid grouping %q (want label=value)", pair)
+		}
+		grouping[name] = labelValue
+	}
+	return grouping, nil
+}
+
+// runPush collects and pushes g every interval until ctx is done. Each push
+// replaces the whole group, so series that disappeared are dropped from the
+// Pushgateway too.
+func runPush(ctx context.Context, cfg pushConfig, g prometheus.Gatherer, failures prometheus.Counter) {
+	pusher := push.New(cfg.url, cfg.job).Gatherer(g)
+	for name, value := range cfg.grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+
+	ticker := time.NewTicker(cfg.interval)
+	defer ticker.Stop()
+	for {
+		pushCtx, cancel := context.WithTimeout(ctx, cfg.interval)
+		if err := pusher.PushContext(pushCtx); err != nil {
+			failures.Inc()
+			log.Printf("Failed to push metrics to %s: %v", cfg.url, err)
+		} else {
+			debugf("Pushed metrics to %s", cfg.url)
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// defaultCorrelationHeader carries the ID generated for each outbound request
+const defaultCorrelationHeader = "X-Correlation-ID"
+
+// correlationHeader parses VANTAGE_CORRELATION_HEADER, where "none" turns
+// the header off
+func correlationHeader(value string) string {
+	value = strings.TrimSpace(value)
+	if strings.EqualFold(value, "none") {
+		return ""
+	}
+	return value
+}
+
+// responseIDHeaders are the headers in which the Vantage API may echo a
+// request ID of its own
+var responseIDHeaders = []string{"X-Request-ID", "X-Correlation-ID", "Request-Id"}
+
+// newRequestID returns a random version 4 UUID
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "unknown"
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// requestIDs describes the correlation ID sent with resp's request and any
+// request ID Vantage answered with, ready to append to a log message. It is
+// empty when there are neither.
+func (c *vantageCollector) requestIDs(resp *http.Response) string {
+	var ids []string
+	if c.correlationHeader != "" && resp.Request != nil {
+		if id := resp.Request.Header.Get(c.correlationHeader); id != "" {
+			ids = append(ids, "correlation ID "+id)
+		}
+	}
+	for _, header := range responseIDHeaders {
+		if id := resp.Header.Get(header); id != "" {
+			ids = append(ids, "Vantage request ID "+id)
+			break
+		}
+	}
+	if len(ids) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(ids, ", ") + ")"
+}
+
+// statusError builds the error for a non-200 response, reading its body
+func (c *vantageCollector) statusError(resp *http.Response) *apiStatusError {
+	return &apiStatusError{statusCode: resp.StatusCode, body: readErrorBody(resp.Body), ids: c.requestIDs(resp)}
+}
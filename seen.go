@@ -0,0 +1,57 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// seenSet remembers the most recently seen transaction IDs so totals built
+// across scrapes count each transaction once. The least recently seen ID is
+// evicted once capacity is reached; an evicted transaction that reappears in
+// a later window is counted again, so capacity should exceed the number of
+// transactions a single scrape can return.
+type seenSet struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front is most recently seen
+	index    map[string]*list.Element
+}
+
+func newSeenSet(capacity int) *seenSet {
+	return &seenSet{
+		capacity: max(capacity, 1),
+		order:    list.New(),
+		index:    make(map[string]*list.Element),
+	}
+}
+
+// has reports whether id has been seen, refreshing it if so
+func (s *seenSet) has(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.index[id]
+	if ok {
+		s.order.MoveToFront(elem)
+	}
+	return ok
+}
+
+// add marks id as seen and reports whether it was new
+func (s *seenSet) add(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.index[id]; ok {
+		s.order.MoveToFront(elem)
+		return false
+	}
+
+	s.index[id] = s.order.PushFront(id)
+	if s.order.Len() > s.capacity {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.index, oldest.Value.(string))
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// checkToken fetches a token once at startup and, if that fails, logs what
+// the failure most likely means. A wrong token path, unreachable host and
+// rejected credentials otherwise all surface as the same failed scrape.
+func (c *vantageCollector) checkToken(ctx context.Context) error {
+	if c.authMode == authModeAPIKey {
+		return nil
+	}
+	_, err := c.getToken(ctx)
+	if err == nil {
+		log.Printf("Startup check for tenant %s: token fetched from %s", c.tenant, c.tokenURL())
+		return nil
+	}
+	log.Printf("Startup check for tenant %s failed: %s", c.tenant, diagnoseTokenError(err, c.tokenURL()))
+	return err
+}
+
+// tokenURL returns the token endpoint URL for log messages
+func (c *vantageCollector) tokenURL() string {
+	u, err := c.apiURL(nil, c.tokenPath...)
+	if err != nil {
+		return c.baseURL
+	}
+	return u
+}
+
+// diagnoseTokenError explains a failed token fetch in terms of the setting
+// that is most likely wrong
+func diagnoseTokenError(err error, tokenURL string) string {
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var statusErr *apiStatusError
+	switch {
+	case errors.As(err, &dnsErr):
+		return fmt.Sprintf("cannot resolve %s; check VANTAGE_BASE_URL and DNS (%v)", dnsErr.Name, err)
+	case errors.As(err, &opErr):
+		return fmt.Sprintf("cannot connect to %s; check VANTAGE_BASE_URL, VANTAGE_PROXY_URL and network access (%v)", tokenURL, err)
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Sprintf("timed out calling %s; check network access or raise VANTAGE_HTTP_TIMEOUT (%v)", tokenURL, err)
+	case errors.As(err, &statusErr) && statusErr.statusCode == http.StatusNotFound:
+		alternative := "/auth/connect/token"
+		if strings.Contains(tokenURL, "/auth/connect/") {
+			alternative = defaultTokenPath
+		}
+		return fmt.Sprintf("token endpoint %s not found; check VANTAGE_TOKEN_PATH (some deployments use %s)", tokenURL, alternative)
+	case errors.As(err, &statusErr) && (statusErr.statusCode == http.StatusBadRequest || statusErr.statusCode == http.StatusUnauthorized):
+		return fmt.Sprintf("credentials rejected; check VANTAGE_CLIENT_ID, VANTAGE_CLIENT_SECRET and VANTAGE_OAUTH_SCOPE (%v)", err)
+	}
+	return err.Error()
+}
+
+// validateConnectivity fetches a token, the skills list and the first page of
+// each transaction list, writing a summary of what it found to w. It reports
+// whether every step succeeded.
+func (c *vantageCollector) validateConnectivity(ctx context.Context, w io.Writer) bool {
+	fmt.Fprintf(w, "Tenant %s (%s)\n", c.tenant, c.baseURL)
+	if err := c.checkToken(ctx); err != nil {
+		fmt.Fprintf(w, "  token:     FAILED: %s\n", diagnoseTokenError(err, c.tokenURL()))
+		return false
+	}
+	if c.authMode == authModeAPIKey {
+		fmt.Fprintf(w, "  token:     not needed with an API key\n")
+	} else {
+		fmt.Fprintf(w, "  token:     ok\n")
+	}
+
+	ok := true
+	skills, err := c.getSkills(ctx)
+	if err != nil {
+		fmt.Fprintf(w, "  skills:    FAILED: %v\n", c.redactError(err))
+		ok = false
+	} else {
+		fmt.Fprintf(w, "  skills:    %d (%d after the allow/deny lists)\n", len(skills), len(c.skillFilter.skills(skills)))
+	}
+
+	for _, list := range []struct {
+		name, kind string
+		filter     url.Values
+	}{
+		{"active", "active transactions", nil},
+		{"completed", "completed transactions", c.completedFilter()},
+	} {
+		page, err := c.getTransactionPage(ctx, list.name, list.kind, list.filter, 0)
+		if err != nil {
+			fmt.Fprintf(w, "  %-10s FAILED: %v\n", list.name+":", c.redactError(err))
+			ok = false
+			continue
+		}
+		fmt.Fprintf(w, "  %-10s %d on the first page, %d in total\n", list.name+":", len(page.Items), page.TotalItemCount)
+	}
+	return ok
+}
+
+// validate runs the connectivity check for every tenant, returning the
+// process exit code
+func validate(collectors []*vantageCollector) int {
+	failed := 0
+	for _, c := range collectors {
+		ctx, cancel := context.WithTimeout(context.Background(), c.scrapeTimeout)
+		if !c.validateConnectivity(ctx, os.Stdout) {
+			failed++
+		}
+		cancel()
+	}
+
+	if failed > 0 {
+		fmt.Printf("Validation failed for %d of %d tenant(s)\n", failed, len(collectors))
+		return 1
+	}
+	fmt.Printf("Validation succeeded for %d tenant(s)\n", len(collectors))
+	return 0
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// The types below follow the Grafana SimpleJSON datasource contract, which
+// the JSON and Infinity datasources also speak.
+
+type simpleJSONRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type simpleJSONTarget struct {
+	Target string `json:"target"`
+	RefID  string `json:"refId"`
+	Type   string `json:"type"` // "timeserie" (default) or "table"
+}
+
+type simpleJSONQuery struct {
+	Range         simpleJSONRange    `json:"range"`
+	IntervalMs    int64              `json:"intervalMs"`
+	MaxDataPoints int64              `json:"maxDataPoints"`
+	Targets       []simpleJSONTarget `json:"targets"`
+}
+
+type simpleJSONTimeseries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+type simpleJSONColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+type simpleJSONTable struct {
+	Type    string             `json:"type"`
+	Columns []simpleJSONColumn `json:"columns"`
+	Rows    [][]interface{}    `json:"rows"`
+}
+
+type simpleJSONAnnotationQuery struct {
+	Range      simpleJSONRange `json:"range"`
+	Annotation struct {
+		Name  string `json:"name"`
+		Query string `json:"query"`
+	} `json:"annotation"`
+}
+
+type simpleJSONAnnotation struct {
+	Annotation interface{} `json:"annotation"`
+	Time       int64       `json:"time"`
+	Title      string      `json:"title"`
+	Text       string      `json:"text"`
+	Tags       []string    `json:"tags"`
+}
+
+const (
+	// defaultMaxDataPoints bounds a timeseries when the query doesn't say how
+	// many points the panel can draw
+	defaultMaxDataPoints = 1000
+	// maxDataPointsLimit caps what a query may ask for, since the series is
+	// allocated up front
+	maxDataPointsLimit = 10000
+)
+
+var simpleJSONTableColumns = []simpleJSONColumn{
+	{Text: "skill_id", Type: "string"},
+	{Text: "skill_name", Type: "string"},
+	{Text: "total_transactions", Type: "number"},
+	{Text: "completed_success", Type: "number"},
+	{Text: "completed_failed", Type: "number"},
+	{Text: "active_processing", Type: "number"},
+	{Text: "active_manual_review", Type: "number"},
+	{Text: "avg_pages_per_transaction", Type: "number"},
+	{Text: "avg_documents_per_transaction", Type: "number"},
+	{Text: "avg_processing_seconds", Type: "number"},
+}
+
+// handleSimpleJSONRoot answers the datasource connection test
+func handleSimpleJSONRoot(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+// handleSearch returns the skill names that can be used as query targets
+func (c *vantageCollector) handleSearch(w http.ResponseWriter, r *http.Request) {
+	skills, err := c.cachedGetSkills(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get skills: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	names := []string{}
+	for _, skill := range c.skillFilter.skills(skills) {
+		names = append(names, skill.Name)
+	}
+	sort.Strings(names)
+	writeJSON(w, http.StatusOK, names)
+}
+
+// handleQuery returns, per target skill, either a timeseries of completed
+// transactions bucketed by completion time or a table of the skill's
+// aggregate transaction metrics
+func (c *vantageCollector) handleQuery(w http.ResponseWriter, r *http.Request) {
+	var query simpleJSONQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		http.Error(w, fmt.Sprintf("invalid query: %v", err), http.StatusBadRequest)
+		return
+	}
+	if query.Range.From.IsZero() || query.Range.To.Before(query.Range.From) {
+		http.Error(w, "query range must have from before to", http.StatusBadRequest)
+		return
+	}
+
+	skills, err := c.cachedGetSkills(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get skills: %v", err), http.StatusBadGateway)
+		return
+	}
+	completed, err := c.getCompletedTransactions(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get completed transactions: %v", err), http.StatusBadGateway)
+		return
+	}
+	completed = c.skillFilter.transactions(completed)
+
+	var active []Transaction
+	for _, target := range query.Targets {
+		if target.Type == "table" {
+			if active, err = c.getActiveTransactions(r.Context()); err != nil {
+				http.Error(w, fmt.Sprintf("failed to get active transactions: %v", err), http.StatusBadGateway)
+				return
+			}
+			active = c.skillFilter.transactions(active)
+			break
+		}
+	}
+
+	interval := time.Duration(query.IntervalMs) * time.Millisecond
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	// Widen the interval rather than return more points than the panel asked for
+	maxPoints := min(query.MaxDataPoints, maxDataPointsLimit)
+	if maxPoints <= 0 {
+		maxPoints = defaultMaxDataPoints
+	}
+	if span := query.Range.To.Sub(query.Range.From); span/interval >= time.Duration(maxPoints) {
+		interval = span/time.Duration(maxPoints) + time.Millisecond
+	}
+
+	results := []interface{}{}
+	for _, target := range query.Targets {
+		skill, ok := resolveSkill(c.skillFilter.skills(skills), target.Target)
+		if !ok {
+			debugf("SimpleJSON query for unknown skill %q", target.Target)
+			continue
+		}
+
+		if target.Type == "table" {
+			m := c.skillTransactionMetrics(skill.ID, skill.Name, active, completed)
+			results = append(results, simpleJSONTable{
+				Type:    "table",
+				Columns: simpleJSONTableColumns,
+				Rows: [][]interface{}{{
+					m.SkillID, m.SkillName, m.TotalTransactions, m.CompletedSuccess, m.CompletedFailed,
+					m.ActiveProcessing, m.ActiveManualReview, m.AveragePages, m.AverageDocuments,
+					m.AverageProcessing,
+				}},
+			})
+			continue
+		}
+
+		results = append(results, simpleJSONTimeseries{
+			Target:     skill.Name,
+			Datapoints: completionSeries(completed, skill.ID, query.Range, interval),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, results)
+	log.Printf("Answered SimpleJSON query for %d targets", len(query.Targets))
+}
+
+// handleAnnotations marks failed transactions in the requested range. The
+// annotation query optionally restricts them to a single skill.
+func (c *vantageCollector) handleAnnotations(w http.ResponseWriter, r *http.Request) {
+	var query simpleJSONAnnotationQuery
+	if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+		http.Error(w, fmt.Sprintf("invalid annotation query: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	skills, err := c.cachedGetSkills(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get skills: %v", err), http.StatusBadGateway)
+		return
+	}
+	completed, err := c.getCompletedTransactions(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get completed transactions: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	skillNames := make(map[string]string)
+	for _, skill := range skills {
+		skillNames[skill.ID] = skill.Name
+	}
+
+	var only string
+	if query.Annotation.Query != "" {
+		skill, ok := resolveSkill(skills, query.Annotation.Query)
+		if !ok {
+			writeJSON(w, http.StatusOK, []simpleJSONAnnotation{})
+			return
+		}
+		only = skill.ID
+	}
+
+	annotations := []simpleJSONAnnotation{}
+	for _, tx := range c.skillFilter.transactions(completed) {
+		if (only != "" && tx.SkillID != only) || c.statuses.classify(tx.Status) != statusFailed {
+			continue
+		}
+		completedAt, ok := parseTimestamp(tx.ID, "completedUtc", tx.CompletedUtc)
+		if !ok || !inRange(completedAt, query.Range) {
+			continue
+		}
+
+		skillName := skillNames[tx.SkillID]
+		if skillName == "" {
+			skillName = tx.SkillID
+		}
+		annotations = append(annotations, simpleJSONAnnotation{
+			Annotation: query.Annotation,
+			Time:       completedAt.UnixMilli(),
+			Title:      fmt.Sprintf("Transaction %s %s", tx.ID, tx.Status),
+			Text:       tx.Error,
+			Tags:       []string{skillName, tx.Status},
+		})
+	}
+
+	writeJSON(w, http.StatusOK, annotations)
+}
+
+// resolveSkill finds a skill by name or ID
+func resolveSkill(skills []Skill, target string) (Skill, bool) {
+	for _, skill := range skills {
+		if skill.Name == target || skill.ID == target {
+			return skill, true
+		}
+	}
+	return Skill{}, false
+}
+
+// completionSeries counts a skill's completed transactions per interval over
+// the query range. Empty buckets are reported as zero so graphs don't
+// interpolate across them.
+func completionSeries(transactions []Transaction, skillID string, rng simpleJSONRange, interval time.Duration) [][2]float64 {
+	counts := make(map[int64]int)
+	for _, tx := range transactions {
+		if tx.SkillID != skillID {
+			continue
+		}
+		completedAt, ok := parseTimestamp(tx.ID, "completedUtc", tx.CompletedUtc)
+		if !ok || !inRange(completedAt, rng) {
+			continue
+		}
+		counts[completedAt.Truncate(interval).UnixMilli()]++
+	}
+
+	datapoints := [][2]float64{}
+	for t := rng.From.Truncate(interval); !t.After(rng.To); t = t.Add(interval) {
+		ts := t.UnixMilli()
+		datapoints = append(datapoints, [2]float64{float64(counts[ts]), float64(ts)})
+	}
+	return datapoints
+}
+
+func inRange(t time.Time, rng simpleJSONRange) bool {
+	return !t.Before(rng.From) && !t.After(rng.To)
+}
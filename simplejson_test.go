@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHandleQueryBoundsDataPoints(t *testing.T) {
+	c := newTestCollector(t, fakeAPI{
+		"skills": respondJSON(t, []Skill{{ID: "s1", Name: "Invoice"}}),
+	})
+
+	for _, tc := range []struct {
+		maxDataPoints int64
+		want          int
+	}{
+		{0, defaultMaxDataPoints},
+		{-5, defaultMaxDataPoints},
+		{1 << 40, maxDataPointsLimit},
+	} {
+		body := `{"range":{"from":"2025-10-14T00:00:00Z","to":"2026-10-14T00:00:00Z"},"intervalMs":1,` +
+			`"maxDataPoints":` + strconv.FormatInt(tc.maxDataPoints, 10) + `,"targets":[{"target":"Invoice"}]}`
+		rec := httptest.NewRecorder()
+		c.handleQuery(rec, httptest.NewRequest(http.MethodPost, "/query", strings.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("maxDataPoints %d: status = %d, want 200: %s", tc.maxDataPoints, rec.Code, rec.Body)
+		}
+
+		var series []simpleJSONTimeseries
+		if err := json.Unmarshal(rec.Body.Bytes(), &series); err != nil {
+			t.Fatal(err)
+		}
+		// Truncating the range start to the interval can add a point
+		if len(series) != 1 {
+			t.Fatalf("got %d series, want 1", len(series))
+		}
+		if len(series[0].Datapoints) > tc.want+1 {
+			t.Errorf("maxDataPoints %d: got %d datapoints, want at most %d", tc.maxDataPoints, len(series[0].Datapoints), tc.want+1)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"unicode"
+)
+
+// Ways VANTAGE_SKILL_NAME_LABEL can normalize skill_name label values
+const (
+	skillNameRaw   = "raw"
+	skillNameTrim  = "trim"
+	skillNameLower = "lower"
+)
+
+// parseSkillNameMode validates VANTAGE_SKILL_NAME_LABEL, falling back to raw
+func parseSkillNameMode(mode string) string {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case skillNameRaw, skillNameTrim, skillNameLower:
+		return mode
+	}
+	log.Printf("Ignoring invalid VANTAGE_SKILL_NAME_LABEL %q (want raw, trim or lower)", mode)
+	return skillNameRaw
+}
+
+// skillNameLabel returns the skill_name label value of a skill. skill_id is
+// the stable identifier; the name is descriptive only, so apart from raw it
+// is trimmed, stripped of unprintable characters and has whitespace runs
+// collapsed to one space, and lower-cased with lower. A name left empty
+// falls back to the skill ID.
+func (c *vantageCollector) skillNameLabel(skill Skill) string {
+	if c.skillNameMode == skillNameRaw {
+		return skill.Name
+	}
+
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsPrint(r) {
+			return r
+		}
+		return -1
+	}, skill.Name)
+	name = strings.Join(strings.Fields(name), " ")
+	if c.skillNameMode == skillNameLower {
+		name = strings.ToLower(name)
+	}
+	if name == "" {
+		return skill.ID
+	}
+	return name
+}
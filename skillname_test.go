@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestSkillNameLabel(t *testing.T) {
+	for _, tc := range []struct {
+		mode string
+		name string
+		want string
+	}{
+		{skillNameRaw, "  Invoice\tEU ", "  Invoice\tEU "},
+		{skillNameRaw, "", ""},
+		{skillNameTrim, "  Invoice\t\n EU ", "Invoice EU"},
+		{skillNameTrim, "Fa\u200bktura Århus", "Faktura Århus"},
+		{skillNameTrim, "Rechnung Üß", "Rechnung Üß"},
+		{skillNameTrim, "   ", "s1"},
+		{skillNameTrim, "", "s1"},
+		{skillNameLower, " Rechnung ÜBER ", "rechnung über"},
+		{skillNameLower, "ΔΕΛΤΑ", "δελτα"},
+		{skillNameLower, "\x00\x7f", "s1"},
+	} {
+		c := &vantageCollector{skillNameMode: tc.mode}
+		if got := c.skillNameLabel(Skill{ID: "s1", Name: tc.name}); got != tc.want {
+			t.Errorf("%s skillNameLabel(%q) = %q, want %q", tc.mode, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestParseSkillNameMode(t *testing.T) {
+	for mode, want := range map[string]string{
+		"raw":    skillNameRaw,
+		" Trim ": skillNameTrim,
+		"LOWER":  skillNameLower,
+		"":       skillNameRaw,
+		"upper":  skillNameRaw,
+	} {
+		if got := parseSkillNameMode(mode); got != want {
+			t.Errorf("parseSkillNameMode(%q) = %q, want %q", mode, got, want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// runBackgroundCollection collects every collectInterval until ctx is done,
+// keeping the result as the snapshot that scrapes are served from. API load
+// then follows the interval rather than the number of scrapers.
+func (c *vantageCollector) runBackgroundCollection(ctx context.Context) {
+	ticker := time.NewTicker(c.collectInterval)
+	defer ticker.Stop()
+	for {
+		c.refreshSnapshot()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshSnapshot runs a full collection and replaces the snapshot with it
+func (c *vantageCollector) refreshSnapshot() {
+	start := time.Now()
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.collect(ch, c.skillFilter)
+		close(ch)
+	}()
+
+	var metrics []prometheus.Metric
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+
+	c.snapshotMu.Lock()
+	c.snapshot = metrics
+	c.snapshotTime = time.Now()
+	c.snapshotMu.Unlock()
+	debugf("Collected snapshot of %d series for tenant %s in %s", len(metrics), c.tenant, time.Since(start).Round(time.Millisecond))
+}
+
+// serveSnapshot sends the last collected snapshot. A non-nil filter further
+// restricts it to series whose skill_id the filter allows; series without a
+// skill are always sent.
+func (c *vantageCollector) serveSnapshot(ch chan<- prometheus.Metric, filter *skillFilter) {
+	c.snapshotMu.RLock()
+	metrics := c.snapshot
+	c.snapshotMu.RUnlock()
+
+	for _, m := range metrics {
+		if filter != nil {
+			if skillID, ok := metricSkillID(m); ok && !filter.allows(skillID) {
+				continue
+			}
+		}
+		ch <- m
+	}
+}
+
+// snapshotAge returns the age of the snapshot in seconds, or 0 before the
+// first one
+func (c *vantageCollector) snapshotAge() float64 {
+	c.snapshotMu.RLock()
+	defer c.snapshotMu.RUnlock()
+	if c.snapshotTime.IsZero() {
+		return 0
+	}
+	return time.Since(c.snapshotTime).Seconds()
+}
+
+// metricSkillID returns the value of a metric's skill_id label
+func metricSkillID(m prometheus.Metric) (string, bool) {
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		return "", false
+	}
+	for _, label := range pb.GetLabel() {
+		if label.GetName() == "skill_id" {
+			return label.GetValue(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// Status categories that raw Vantage status strings are normalized into
+const (
+	statusSuccess      = "success"
+	statusFailed       = "failed"
+	statusProcessing   = "processing"
+	statusManualReview = "manual_review"
+	statusUnknown      = "unknown"
+)
+
+// defaultStatusCategories maps lower-cased Vantage statuses to categories
+var defaultStatusCategories = map[string]string{
+	"finished successfully": statusSuccess,
+	"succeeded":             statusSuccess,
+	"failed":                statusFailed,
+	"processing":            statusProcessing,
+	"in progress":           statusProcessing,
+	"new":                   statusProcessing,
+	"manual review":         statusManualReview,
+	"manualreview":          statusManualReview,
+}
+
+// statusClassifier maps raw transaction statuses to a fixed set of categories,
+// ignoring case and surrounding whitespace
+type statusClassifier map[string]string
+
+// newStatusClassifier returns the default mapping extended by overrides, a
+// comma-separated list of status=category pairs
+func newStatusClassifier(overrides string) statusClassifier {
+	s := make(statusClassifier, len(defaultStatusCategories))
+	for status, category := range defaultStatusCategories {
+		s[status] = category
+	}
+
+	for _, pair := range strings.Split(overrides, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		status, category, ok := strings.Cut(pair, "=")
+		category = strings.TrimSpace(category)
+		if !ok || !validStatusCategory(category) {
+			log.Printf("Ignoring invalid status mapping %q (want status=success|failed|processing|manual_review)", pair)
+			continue
+		}
+		s[normalizeStatus(status)] = category
+	}
+	return s
+}
+
+func (s statusClassifier) classify(status string) string {
+	if category, ok := s[normalizeStatus(status)]; ok {
+		return category
+	}
+	return statusUnknown
+}
+
+func normalizeStatus(status string) string {
+	return strings.ToLower(strings.TrimSpace(status))
+}
+
+func validStatusCategory(category string) bool {
+	switch category {
+	case statusSuccess, statusFailed, statusProcessing, statusManualReview:
+		return true
+	}
+	return false
+}
+
+// otherStatus is the status label of completions whose status is not in
+// VANTAGE_COUNTED_STATUSES
+const otherStatus = "other"
+
+// newCountedStatuses returns the lower-cased set of statuses counted under
+// their own label, or nil to count every status as is
+func newCountedStatuses(statuses []string) map[string]bool {
+	if len(statuses) == 0 {
+		return nil
+	}
+	counted := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		counted[normalizeStatus(status)] = true
+	}
+	return counted
+}
+
+// countedStatus returns the status label a completion is counted under,
+// collapsing statuses outside VANTAGE_COUNTED_STATUSES into "other"
+func (c *vantageCollector) countedStatus(status string) string {
+	if c.countedStatuses == nil || c.countedStatuses[normalizeStatus(status)] {
+		return status
+	}
+	return otherStatus
+}
@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestCountedStatuses(t *testing.T) {
+	t.Setenv("VANTAGE_COUNTED_STATUSES", "finished successfully")
+	t.Setenv("VANTAGE_STATUS_MAPPING", "quarantined=failed")
+	completed := func(id, status string) Transaction {
+		return Transaction{ID: id, SkillID: "s1", Status: status, CreateTimeUtc: "2026-10-14T10:00:00Z", CompletedUtc: "2026-10-14T10:01:00Z"}
+	}
+	c := newTestCollector(t, fakeAPI{
+		"skills": respondJSON(t, []Skill{{ID: "s1", Name: "Invoice"}}),
+		"completed": respondJSON(t, transactionList(
+			completed("c1", "Finished Successfully"),
+			completed("c2", "Finished Successfully"),
+			completed("c3", "Quarantined"),
+			completed("c4", "Archived"),
+		)),
+	})
+	if got := c.countedStatus("Quarantined"); got != otherStatus {
+		t.Fatalf("countedStatus(Quarantined) = %q, want %q", got, otherStatus)
+	}
+
+	compareMetrics(t, c, `
+# HELP vantage_completed_transactions_total Completed transactions seen since the exporter started by skill, raw status (other when outside VANTAGE_COUNTED_STATUSES) and normalized status category. Each transaction is counted once across scrapes
+# TYPE vantage_completed_transactions_total counter
+vantage_completed_transactions_total{category="failed",skill_id="s1",status="other",tenant="test"} 1
+vantage_completed_transactions_total{category="success",skill_id="s1",status="Finished Successfully",tenant="test"} 2
+vantage_completed_transactions_total{category="unknown",skill_id="s1",status="other",tenant="test"} 1
+`, "vantage_completed_transactions_total")
+}
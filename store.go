@@ -0,0 +1,168 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// transactionStore keeps the most recently fetched transactions keyed by ID
+// so they can be looked up without another API call. Entries expire after
+// ttl and the least recently updated entry is evicted once capacity is
+// reached.
+type transactionStore struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List // front is most recently updated
+	index    map[string]*list.Element
+}
+
+type storedTransaction struct {
+	tx      Transaction
+	updated time.Time
+}
+
+func newTransactionStore(capacity int, ttl time.Duration) *transactionStore {
+	return &transactionStore{
+		capacity: max(capacity, 1),
+		ttl:      ttl,
+		order:    list.New(),
+		index:    make(map[string]*list.Element),
+	}
+}
+
+// put records the latest state of each transaction
+func (s *transactionStore) put(transactions []Transaction) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, tx := range transactions {
+		if elem, ok := s.index[tx.ID]; ok {
+			elem.Value = storedTransaction{tx: tx, updated: now}
+			s.order.MoveToFront(elem)
+			continue
+		}
+		s.index[tx.ID] = s.order.PushFront(storedTransaction{tx: tx, updated: now})
+	}
+
+	for s.order.Len() > s.capacity {
+		s.removeLocked(s.order.Back())
+	}
+	s.expireLocked(now)
+}
+
+// get returns the stored transaction with the given ID, if it hasn't expired
+func (s *transactionStore) get(id string) (Transaction, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.index[id]
+	if !ok {
+		return Transaction{}, false
+	}
+	entry := elem.Value.(storedTransaction)
+	if time.Since(entry.updated) > s.ttl {
+		s.removeLocked(elem)
+		return Transaction{}, false
+	}
+	return entry.tx, true
+}
+
+// len returns the number of stored transactions after dropping expired ones
+func (s *transactionStore) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expireLocked(time.Now())
+	return s.order.Len()
+}
+
+// expireLocked drops entries older than ttl. Entries are ordered by update
+// time, so it stops at the first fresh one. The caller must hold mu.
+func (s *transactionStore) expireLocked(now time.Time) {
+	for elem := s.order.Back(); elem != nil; elem = s.order.Back() {
+		if now.Sub(elem.Value.(storedTransaction).updated) <= s.ttl {
+			return
+		}
+		s.removeLocked(elem)
+	}
+}
+
+func (s *transactionStore) removeLocked(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.index, elem.Value.(storedTransaction).tx.ID)
+}
+
+// detailCache keeps the detail of finished transactions, which no longer
+// changes, so repeated scrapes and lookups don't fetch it again. Entries
+// expire after ttl, or never when ttl is zero, and the least recently used
+// entry is evicted once capacity is reached.
+type detailCache struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	order    *list.List // front is most recently used
+	index    map[string]*list.Element
+}
+
+type cachedDetail struct {
+	detail *TransactionDetail
+	added  time.Time
+}
+
+func newDetailCache(capacity int, ttl time.Duration) *detailCache {
+	return &detailCache{
+		capacity: max(capacity, 1),
+		ttl:      ttl,
+		order:    list.New(),
+		index:    make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached detail of the given transaction, if any
+func (d *detailCache) get(id string) (*TransactionDetail, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	elem, ok := d.index[id]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(cachedDetail)
+	if d.ttl > 0 && time.Since(entry.added) > d.ttl {
+		d.order.Remove(elem)
+		delete(d.index, id)
+		return nil, false
+	}
+	d.order.MoveToFront(elem)
+	return entry.detail, true
+}
+
+// put caches a transaction's detail
+func (d *detailCache) put(detail *TransactionDetail) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry := cachedDetail{detail: detail, added: time.Now()}
+	if elem, ok := d.index[detail.ID]; ok {
+		elem.Value = entry
+		d.order.MoveToFront(elem)
+		return
+	}
+
+	d.index[detail.ID] = d.order.PushFront(entry)
+	if d.order.Len() > d.capacity {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.index, oldest.Value.(cachedDetail).detail.ID)
+	}
+}
+
+// len returns the number of cached details
+func (d *detailCache) len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.order.Len()
+}
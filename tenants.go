@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultBaseURL is used when a tenant does not specify a base URL
+const defaultBaseURL = "https://vantage-us.abbyy.com"
+
+// tenantConfig identifies a Vantage tenant and the credentials used to reach it
+type tenantConfig struct {
+	Name         string `json:"name" yaml:"name"`
+	BaseURL      string `json:"base_url" yaml:"base_url"`
+	ClientID     string `json:"client_id" yaml:"client_id"`
+	ClientSecret string `json:"client_secret" yaml:"client_secret"`
+	APIKey       string `json:"api_key" yaml:"api_key"`
+}
+
+// defaultTenant builds the single tenant configured through environment variables
+func defaultTenant() tenantConfig {
+	return tenantConfig{
+		Name:         getEnv("VANTAGE_TENANT", "default"),
+		BaseURL:      getEnv("VANTAGE_BASE_URL", defaultBaseURL),
+		ClientID:     getEnv("VANTAGE_CLIENT_ID", ""),
+		ClientSecret: getEnv("VANTAGE_CLIENT_SECRET", ""),
+		APIKey:       getEnv("VANTAGE_API_KEY", ""),
+	}
+}
+
+// loadTenantsFile reads a JSON array of tenants. Names must be unique since
+// they become the tenant label on every metric.
+func loadTenantsFile(path string) ([]tenantConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tenants []tenantConfig
+	if err := json.Unmarshal(data, &tenants); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if err := normalizeTenants(tenants); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tenants, nil
+}
+
+// normalizeTenants checks that tenant names are present and unique and fills
+// in the default base URL
+func normalizeTenants(tenants []tenantConfig) error {
+	if len(tenants) == 0 {
+		return fmt.Errorf("no tenants defined")
+	}
+
+	seen := make(map[string]bool)
+	for i := range tenants {
+		if tenants[i].Name == "" {
+			return fmt.Errorf("tenant %d has no name", i)
+		}
+		if seen[tenants[i].Name] {
+			return fmt.Errorf("duplicate tenant name %q", tenants[i].Name)
+		}
+		seen[tenants[i].Name] = true
+		if tenants[i].BaseURL == "" {
+			tenants[i].BaseURL = defaultBaseURL
+		}
+	}
+	return nil
+}
+
+// tenantRouter dispatches HTTP requests to the collector of the requested tenant
+type tenantRouter struct {
+	collectors []*vantageCollector
+	byName     map[string]*vantageCollector
+}
+
+func newTenantRouter(collectors []*vantageCollector) *tenantRouter {
+	byName := make(map[string]*vantageCollector, len(collectors))
+	for _, c := range collectors {
+		byName[c.tenant] = c
+	}
+	return &tenantRouter{collectors: collectors, byName: byName}
+}
+
+// lookup returns the named tenant's collector. The name may be omitted when
+// only one tenant is configured.
+func (t *tenantRouter) lookup(name string) (*vantageCollector, error) {
+	if name == "" {
+		if len(t.collectors) == 1 {
+			return t.collectors[0], nil
+		}
+		return nil, fmt.Errorf("tenant parameter required (one of: %s)", strings.Join(t.names(), ", "))
+	}
+	c, ok := t.byName[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown tenant %q (one of: %s)", name, strings.Join(t.names(), ", "))
+	}
+	return c, nil
+}
+
+func (t *tenantRouter) names() []string {
+	names := make([]string, 0, len(t.byName))
+	for name := range t.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// handle wraps a collector handler so it runs against the tenant selected by
+// the tenant query parameter
+func (t *tenantRouter) handle(h func(*vantageCollector, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := t.lookup(r.URL.Query().Get("tenant"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h(c, w, r)
+	}
+}
+
+// handleReadyz reports ready only when every tenant is ready
+func (t *tenantRouter) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	for _, c := range t.collectors {
+		if err := c.checkReady(r.Context()); err != nil {
+			http.Error(w, fmt.Sprintf("tenant %q not ready: %v", c.tenant, err), http.StatusServiceUnavailable)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestMetricsIncludeRuntimeMetrics(t *testing.T) {
+	c := newTestCollector(t, fakeAPI{
+		"skills": respondJSON(t, []Skill{{ID: "s1", Name: "Invoice"}, {ID: "s2", Name: "Receipt"}}),
+	})
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+	handler := metricsHandler(registry, newRuntimeRegistry(), []*vantageCollector{c})
+
+	want := []string{"go_goroutines", "go_memstats_alloc_bytes", "vantage_exporter_build_info"}
+	// The process collector only reports on platforms with procfs
+	if runtime.GOOS == "linux" {
+		want = append(want, "process_resident_memory_bytes", "process_cpu_seconds_total")
+	}
+	// A skills filter narrows the Vantage series but not the runtime ones
+	for target, skills := range map[string][]string{
+		"/metrics":           {"s1", "s2"},
+		"/metrics?skills=s1": {"s1"},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s status = %d, want 200: %s", target, rec.Code, rec.Body)
+		}
+		body := rec.Body.String()
+		for _, name := range want {
+			if !strings.Contains(body, "\n"+name) {
+				t.Errorf("%s is missing %s", target, name)
+			}
+		}
+		if n := strings.Count(body, "\nvantage_skill_info{"); n != len(skills) {
+			t.Errorf("%s has %d skill_info series, want %d for skills %v", target, n, len(skills), skills)
+		}
+	}
+}